@@ -125,6 +125,11 @@ type CacheConfig struct {
 	GuildCacheLimitMiB  uint
 	GuildCacheLifetime  time.Duration
 	GuildCacheAlgorithm string
+
+	// LoadOfflineMembers requests the offline members of every large guild over the gateway once the guild is
+	// created, such that the cached member list is complete. The guilds of a shard are loaded one at a time, and a
+	// GuildMembersLoaded event is dispatched for every guild. See Config.GuildLargeThreshold.
+	LoadOfflineMembers bool
 }
 
 // Cache is the actual cache. It holds the different systems which can be tweaked using the CacheConfig.
@@ -26,12 +26,49 @@ type Config struct {
 
 	CancelRequestWhenRateLimited bool
 
+	// RetryPolicy decides when REST requests are retried. Defaults to httd.DefaultRetryPolicy.
+	RetryPolicy *httd.RetryPolicy
+
+	// RateLimiter keeps track of the REST rate limits. Use httd.NewDistributedRateLimit to share the rate limits
+	// between processes using the same bot token. Defaults to an in memory rate limiter.
+	RateLimiter httd.RateLimiter
+
 	CacheConfig *CacheConfig
 
+	// ShardConfig decides how many shards this session runs. See ShardConfig.
+	ShardConfig ShardConfig
+
+	// ShardID, TotalShards and WebsocketURL are kept for compatibility. Setting TotalShards makes the session
+	// run the single shard ShardID, unless ShardConfig is specified.
 	ShardID      uint
 	TotalShards  uint
 	WebsocketURL string
 
+	// CompressGateway enables zlib-stream transport compression for the gateway connections, which reduces the
+	// bandwidth used by shards with large guilds at the cost of some CPU.
+	CompressGateway bool
+
+	// GatewayEncoding is the encoding used by the gateway connections. Either constant.JSONEncoding (default) or
+	// constant.ETFEncoding.
+	GatewayEncoding string
+
+	// ReconnectPolicy decides how long the shards wait between reconnect attempts when their connection is lost.
+	// Defaults to websocket.DefaultReconnectPolicy, which never gives up.
+	ReconnectPolicy *websocket.ReconnectPolicy
+
+	// GuildLargeThreshold is the member count, between 50 and 250, from which Discord only sends the online
+	// members of a guild when the bot connects. See CacheConfig.LoadOfflineMembers. Defaults to 250, and
+	// NewSession returns an error for values outside of the range.
+	GuildLargeThreshold uint
+
+	// Presence is the status the bot has once it comes online. See Client.UpdateStatus to change it later on.
+	Presence *UpdateStatusCommand
+
+	// RejectRateLimitedCommands makes socket commands fail with a *websocket.ErrorCommandBudget when a shard has
+	// used up its command budget, instead of waiting until they can be sent. See websocket.CommandBudget. The
+	// error is only reported by UpdateStatus and RequestGuildMembers, as Emit does not return errors.
+	RejectRateLimitedCommands bool
+
 	//ImmutableCache bool
 
 	//LoadAllMembers   bool
@@ -54,9 +91,10 @@ type Client struct {
 	config *Config
 	token  string
 
-	connected     sync.Mutex
-	ws            *websocket.Client
-	socketEvtChan <-chan *websocket.Event
+	connected        sync.Mutex
+	shardMngr        *ShardManager
+	socketEvtChan    <-chan *websocket.Event
+	eventHandlerOnce *sync.Once
 
 	myID Snowflake
 
@@ -75,13 +113,23 @@ type Client struct {
 
 	// cache
 	cache *Cache
+
+	// memberRequests pairs the GuildMembersChunk events with the RequestGuildMembers calls
+	memberRequests *guildMembersRequests
+
+	// offlineMembers loads the members of large guilds, see CacheConfig.LoadOfflineMembers
+	offlineMembers *offlineMembersLoader
+
+	// membersLoaded hands the GuildMembersLoaded events over to the event handler, such that they are dispatched
+	// in line with the gateway events
+	membersLoaded chan *GuildMembersLoaded
 }
 
 // HeartbeatLatency checks the duration of waiting before receiving a response from Discord when a
 // heartbeat packet was sent. Note that heartbeats are usually sent around once a minute and is not a accurate
 // way to measure delay between the client and Discord server
 func (c *Client) HeartbeatLatency() (duration time.Duration, err error) {
-	return c.ws.HeartbeatLatency()
+	return c.shardMngr.HeartbeatLatency()
 }
 
 // ShardID ...
@@ -94,6 +142,11 @@ func (c *Client) ShardIDString() string {
 	return strconv.Itoa(int(c.ShardID()))
 }
 
+// ShardManager returns the shard manager which holds the websocket connection of every shard run by this session
+func (c *Client) ShardManager() *ShardManager {
+	return c.shardMngr
+}
+
 // Myself get the current user / connected user
 func (c *Client) Myself() (user *User, err error) {
 	if c.myID.Empty() {
@@ -133,7 +186,8 @@ func (c *Client) RateLimiter() httd.RateLimiter {
 	return c.req.RateLimiter()
 }
 
-// Connect establishes a websocket connection to the discord API
+// Connect establishes a websocket connection to the discord API, and returns once every shard is ready. A
+// *websocket.ErrorFatalClose is returned if Discord refuses the connection, such as for an invalid token.
 func (c *Client) Connect() (err error) {
 	// set the user ID upon connection
 	// only works for socketing
@@ -146,16 +200,19 @@ func (c *Client) Connect() (err error) {
 
 	c.logInfo("Connecting to discord Gateway")
 	c.evtDispatch.start()
-	err = c.ws.Connect()
+
+	// setup event observer, the shards dispatch connection state changes while connecting
+	c.eventHandlerOnce.Do(func() {
+		go c.eventHandler()
+	})
+
+	err = c.shardMngr.Connect()
 	if err != nil {
 		c.logErr(err.Error())
 		return
 	}
 	c.logInfo("Connected")
 
-	// setup event observer
-	go c.eventHandler()
-
 	return nil
 }
 
@@ -164,7 +221,7 @@ func (c *Client) Disconnect() (err error) {
 	fmt.Println() // to keep ^C on it's own line
 	c.logInfo("Closing Discord gateway connection")
 	c.evtDispatch.stop()
-	err = c.ws.Disconnect()
+	err = c.shardMngr.Disconnect()
 	if err != nil {
 		c.logErr(err.Error())
 		return
@@ -184,6 +241,34 @@ func (c *Client) DisconnectOnInterrupt() (err error) {
 	return c.Disconnect()
 }
 
+// WithContext returns the REST methods of the session bound to the given context. The context is honoured while
+// waiting for rate limits and during the http round trip, so a request can be aborted on timeout or shutdown:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+//	defer cancel()
+//	channel, err := session.WithContext(ctx).GetChannel(channelID)
+//
+// The returned RESTer shares the cache, rate limits and member requests with the session.
+func (c *Client) WithContext(ctx context.Context) RESTer {
+	// every field but the locks is copied, the state shared with the session is held by pointers
+	return &Client{
+		config:                       c.config,
+		token:                        c.token,
+		shardMngr:                    c.shardMngr,
+		socketEvtChan:                c.socketEvtChan,
+		eventHandlerOnce:             c.eventHandlerOnce,
+		myID:                         c.myID,
+		evtDispatch:                  c.evtDispatch,
+		cancelRequestWhenRateLimited: c.cancelRequestWhenRateLimited,
+		req:                          c.req.WithContext(ctx),
+		httpClient:                   c.httpClient,
+		cache:                        c.cache,
+		memberRequests:               c.memberRequests,
+		offlineMembers:               c.offlineMembers,
+		membersLoaded:                c.membersLoaded,
+	}
+}
+
 // Req return the request object. Used in REST requests to handle rate limits,
 // wrong http responses, etc.
 func (c *Client) Req() httd.Requester {
@@ -206,14 +291,42 @@ func (c *Client) Once(event string, handlers ...interface{}) {
 	c.evtDispatch.Once(event, handlers...)
 }
 
-// Emit sends a socket command directly to Discord.
+// Use adds middlewares that run before the handlers of every event. See Middleware.
+func (c *Client) Use(middlewares ...Middleware) {
+	c.evtDispatch.Use(middlewares...)
+}
+
+// UseOn adds middlewares that run before the handlers of the given event. See Middleware.
+func (c *Client) UseOn(event string, middlewares ...Middleware) {
+	c.evtDispatch.UseOn(event, middlewares...)
+}
+
+// Emit sends a socket command directly to Discord. Commands referencing a guild are sent to the shard of that guild,
+// while the remaining commands are sent to every shard. Errors are discarded, including those of
+// Config.RejectRateLimitedCommands; use UpdateStatus or RequestGuildMembers to have them reported.
 func (c *Client) Emit(command SocketCommand, data interface{}) {
 	switch command {
 	case CommandUpdateStatus, CommandUpdateVoiceState, CommandRequestGuildMembers:
 	default:
 		return
 	}
-	c.ws.Emit(command, data)
+	c.shardMngr.Emit(command, data)
+}
+
+// UpdateStatus updates the presence of the bot on every shard, with an optional activity such as the game being
+// played. The presence is kept when a shard identifies again.
+func (c *Client) UpdateStatus(status string, activity *Activity) (err error) {
+	presence := &UpdateStatusCommand{
+		Status: status,
+	}
+	if activity != nil {
+		presence.Game = activity.DeepCopy().(*Activity)
+	}
+	if err = presence.validate(); err != nil {
+		return
+	}
+
+	return c.shardMngr.Emit(CommandUpdateStatus, presence)
 }
 
 // EventChan get a event channel using the event name
@@ -230,7 +343,7 @@ func (c *Client) EventChannels() (channels EventChannels) {
 // to improve performance.
 func (c *Client) AcceptEvent(events ...string) {
 	for _, evt := range events {
-		c.ws.RegisterEvent(evt)
+		c.shardMngr.RegisterEvent(evt)
 	}
 }
 
@@ -884,8 +997,8 @@ func (c *Client) DeleteWebhookWithToken(id Snowflake, token string) (err error)
 }
 
 // ExecuteWebhook .
-func (c *Client) ExecuteWebhook(params *ExecuteWebhookParams, wait bool, URLSuffix string) (err error) {
-	err = ExecuteWebhook(c.req, params, wait, URLSuffix)
+func (c *Client) ExecuteWebhook(params *ExecuteWebhookParams, wait bool, URLSuffix string) (ret *Message, err error) {
+	ret, err = ExecuteWebhook(c.req, params, wait, URLSuffix)
 	return
 }
 
@@ -901,6 +1014,18 @@ func (c *Client) ExecuteGitHubWebhook(params *ExecuteWebhookParams, wait bool) (
 	return
 }
 
+// EditWebhookMessage .
+func (c *Client) EditWebhookMessage(id Snowflake, token string, msgID Snowflake, params *EditWebhookMessageParams) (ret *Message, err error) {
+	ret, err = EditWebhookMessage(c.req, id, token, msgID, params)
+	return
+}
+
+// DeleteWebhookMessage .
+func (c *Client) DeleteWebhookMessage(id Snowflake, token string, msgID Snowflake) (err error) {
+	err = DeleteWebhookMessage(c.req, id, token, msgID)
+	return
+}
+
 // Custom methods are usually reused by the resource package for readability
 // -----
 
@@ -964,16 +1089,6 @@ func (c *Client) UpdateChannel(channel *Channel) (err error) {
 	return errors.New("not implemented")
 }
 
-func waitForEvent(eventEmitter <-chan *websocket.Event) (event *websocket.Event, err error) {
-	var alive bool
-	event, alive = <-eventEmitter
-	if !alive {
-		err = errors.New("event emitter (channel) is dead")
-	}
-
-	return
-}
-
 // eventHandler Takes a incoming event from the websocket package, parses it, and sends
 // trigger requests to the event dispatcher and state cacher.
 func (c *Client) eventHandler() {
@@ -981,9 +1096,17 @@ func (c *Client) eventHandler() {
 		var err error
 		var evt *websocket.Event
 
-		evt, err = waitForEvent(c.socketEvtChan)
-		if err != nil {
-			return
+		select {
+		case e, alive := <-c.socketEvtChan:
+			if !alive {
+				return
+			}
+			evt = e
+		case loaded := <-c.membersLoaded:
+			ctx := context.Background()
+			loaded.registerContext(ctx)
+			c.evtDispatch.dispatch(ctx, EventGuildMembersLoaded, c, loaded)
+			continue
 		}
 
 		var box eventBox
@@ -993,6 +1116,8 @@ func (c *Client) eventHandler() {
 			box = &Ready{}
 		case EventResumed:
 			box = &Resumed{}
+		case EventConnectionStateChange:
+			box = &ConnectionStateChange{}
 		case EventChannelCreate:
 			box = &ChannelCreate{}
 		case EventChannelUpdate:
@@ -1079,10 +1204,17 @@ func (c *Client) eventHandler() {
 
 		// cache
 		c.cacheEvent(evt.Name, box)
+		switch e := box.(type) {
+		case *GuildMembersChunk:
+			c.memberRequests.deliver(e)
+		case *GuildCreate:
+			if c.config.CacheConfig != nil && c.config.CacheConfig.LoadOfflineMembers {
+				c.loadOfflineMembers(e.Guild)
+			}
+		}
 
 		// trigger listeners
-		c.evtDispatch.triggerChan(ctx, evt.Name, c, box)
-		go c.evtDispatch.triggerCallbacks(ctx, evt.Name, c, box)
+		c.evtDispatch.dispatch(ctx, evt.Name, c, box)
 	}
 }
 
@@ -1138,6 +1270,14 @@ func (c *Client) cacheEvent(event string, v interface{}) (err error) {
 				updates[UserCache][i] = guild.Members[i].User
 			}
 		}
+	case EventGuildMembersChunk:
+		chunk := v.(*GuildMembersChunk)
+		c.cache.SetGuildMembers(chunk.GuildID, chunk.Members)
+		for i := range chunk.Members {
+			if chunk.Members[i].User != nil {
+				updates[UserCache] = append(updates[UserCache], chunk.Members[i].User)
+			}
+		}
 	case EventGuildDelete:
 		uguild := (v.(*GuildDelete)).UnavailableGuild
 		c.cache.DeleteGuild(uguild.ID)
@@ -1163,7 +1303,6 @@ func (c *Client) cacheEvent(event string, v interface{}) (err error) {
 		//case EventGuildMemberAdd:
 		//case EventGuildMemberRemove:
 		//case EventGuildMemberUpdate:
-		//case EventGuildMembersChunk:
 		//case EventGuildRoleCreate:
 		//case EventGuildRoleUpdate:
 		//case EventMessageUpdate:
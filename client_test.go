@@ -0,0 +1,62 @@
+package disgord
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// newTestConfig returns a config which NewSession accepts without connecting to Discord
+func newTestConfig() *Config {
+	return &Config{
+		Token: "test",
+		CacheConfig: &CacheConfig{
+			UserCacheAlgorithm:       CacheAlgLRU,
+			VoiceStateCacheAlgorithm: CacheAlgLRU,
+			ChannelCacheAlgorithm:    CacheAlgLRU,
+		},
+	}
+}
+
+func TestClient_WithContext(t *testing.T) {
+	session, err := NewSession(newTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := session.(*Client)
+	rest := c.WithContext(context.Background()).(*Client)
+
+	if rest.req == c.req {
+		t.Error("expected the requests to be bound to the context")
+	}
+
+	// every other field must be shared with the session, except for the locks
+	original, clone := reflect.ValueOf(c).Elem(), reflect.ValueOf(rest).Elem()
+	for i := 0; i < original.NumField(); i++ {
+		field := original.Type().Field(i)
+		if field.Name == "req" || field.Type.PkgPath() == "sync" {
+			continue
+		}
+		if fmt.Sprint(original.Field(i)) != fmt.Sprint(clone.Field(i)) {
+			t.Errorf("expected field %s to be copied", field.Name)
+		}
+	}
+}
+
+func TestNewSession_GuildLargeThreshold(t *testing.T) {
+	for _, threshold := range []uint{0, 50, 250} {
+		conf := newTestConfig()
+		conf.GuildLargeThreshold = threshold
+		if _, err := NewSession(conf); err != nil {
+			t.Errorf("expected a threshold of %d to be accepted, got %v", threshold, err)
+		}
+	}
+	for _, threshold := range []uint{49, 251} {
+		conf := newTestConfig()
+		conf.GuildLargeThreshold = threshold
+		if _, err := NewSession(conf); err == nil {
+			t.Errorf("expected a threshold of %d to be refused", threshold)
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package disgord
 
+import (
+	"errors"
+	"strconv"
+
+	"github.com/andersfylling/disgord/httd"
+)
+
 // SocketCommand represents the type used to emit commands to Discord
 // over the socket connection
 type SocketCommand = string
@@ -24,6 +31,33 @@ type RequestGuildMembersCommand struct {
 
 	// Limit maximum number of members to send or 0 to request all members matched
 	Limit uint `json:"limit"`
+
+	// UserIDs requests the given members instead of matching the Query
+	UserIDs []Snowflake `json:"user_ids,omitempty"`
+
+	// Presences requests the presences of the members as well
+	Presences bool `json:"presences,omitempty"`
+
+	// Nonce is returned in the GuildMembersChunk events, such that they can be told apart from the chunks of
+	// other requests. See Client.RequestGuildMembers.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// requestGuildMembersCommand avoids recursion in RequestGuildMembersCommand.MarshalJSON
+type requestGuildMembersCommand RequestGuildMembersCommand
+
+// MarshalJSON leaves out the query when specific members are requested, as Discord only accepts one of them
+func (r *RequestGuildMembersCommand) MarshalJSON() ([]byte, error) {
+	if len(r.UserIDs) == 0 {
+		return httd.Marshal((*requestGuildMembersCommand)(r))
+	}
+
+	return httd.Marshal(&struct {
+		*requestGuildMembersCommand
+		Query string `json:"query,omitempty"`
+	}{
+		requestGuildMembersCommand: (*requestGuildMembersCommand)(r),
+	})
 }
 
 // CommandUpdateVoiceState Sent when a client wants to join, move, or
@@ -58,7 +92,7 @@ type UpdateStatusCommand struct {
 	Since *uint `json:"since"`
 
 	// Game null, or the user's new activity
-	Game *Activity `json:"activity"`
+	Game *Activity `json:"game"`
 
 	// Status the user's new status
 	Status string `json:"status"`
@@ -66,3 +100,29 @@ type UpdateStatusCommand struct {
 	// AFK whether or not the client is afk
 	AFK bool `json:"afk"`
 }
+
+// validate checks that the status and activity are accepted by Discord
+func (u *UpdateStatusCommand) validate() (err error) {
+	switch u.Status {
+	case StatusOnline, StatusIdle, StatusDnd, StatusInvisible, StatusOffline:
+	default:
+		return errors.New("unsupported status: " + strconv.Quote(u.Status))
+	}
+
+	if u.Game == nil {
+		return
+	}
+	if u.Game.Name == "" {
+		return newErrorEmptyValue("the activity must have a name")
+	}
+	switch u.Game.Type {
+	case ActivityTypeGame, ActivityTypeListening, ActivityTypeWatching:
+	case ActivityTypeStreaming:
+		if u.Game.URL == nil || *u.Game.URL == "" {
+			return newErrorEmptyValue("a streaming activity must have a stream url")
+		}
+	default:
+		return errors.New("unsupported activity type: " + strconv.Itoa(u.Game.Type))
+	}
+	return
+}
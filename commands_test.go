@@ -0,0 +1,49 @@
+package disgord
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andersfylling/disgord/httd"
+)
+
+func TestUpdateStatusCommand_validate(t *testing.T) {
+	url := "https://www.twitch.tv/disgord"
+	testCases := []struct {
+		name    string
+		command *UpdateStatusCommand
+		valid   bool
+	}{
+		{"online", &UpdateStatusCommand{Status: StatusOnline}, true},
+		{"invisible", &UpdateStatusCommand{Status: StatusInvisible}, true},
+		{"unknown status", &UpdateStatusCommand{Status: "away"}, false},
+		{"empty status", &UpdateStatusCommand{}, false},
+		{"game", &UpdateStatusCommand{Status: StatusDnd, Game: &Activity{Name: "chess"}}, true},
+		{"nameless activity", &UpdateStatusCommand{Status: StatusIdle, Game: &Activity{}}, false},
+		{"stream", &UpdateStatusCommand{Status: StatusOnline, Game: &Activity{Name: "chess", Type: ActivityTypeStreaming, URL: &url}}, true},
+		{"stream without url", &UpdateStatusCommand{Status: StatusOnline, Game: &Activity{Name: "chess", Type: ActivityTypeStreaming}}, false},
+		{"unknown activity type", &UpdateStatusCommand{Status: StatusOnline, Game: &Activity{Name: "chess", Type: 42}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.command.validate()
+			if tc.valid && err != nil {
+				t.Errorf("expected the command to be valid, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected the command to be refused")
+			}
+		})
+	}
+}
+
+func TestUpdateStatusCommand_MarshalJSON(t *testing.T) {
+	data, err := httd.Marshal(&UpdateStatusCommand{Status: StatusOnline, Game: &Activity{Name: "chess"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"game":{`) {
+		t.Errorf("expected the activity to be sent as the game, got %s", string(data))
+	}
+}
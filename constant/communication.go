@@ -5,3 +5,6 @@ const DiscordVersion = 6
 
 // JSONEncoding the json encoding identifier
 const JSONEncoding = "json"
+
+// ETFEncoding the External Term Format (erlpack) encoding identifier
+const ETFEncoding = "etf"
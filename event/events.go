@@ -22,6 +22,17 @@ const Ready = "READY"
 //  - Trace []string
 const Resumed = "RESUMED"
 
+// ConnectionStateChange Sent by Disgord when the gateway connection of a shard changes state, such as when it
+// is lost and reconnecting. This is not a Discord event.
+//  Fields:
+//  - ShardID uint
+//  - From    websocket.ConnectionState
+//  - To      websocket.ConnectionState
+//  - Attempt uint
+//  - Delay   time.Duration
+//  - Reason  string
+const ConnectionStateChange = "CONNECTION_STATE_CHANGE"
+
 // ChannelCreate Sent when a new channel is created, relevant to the current user. The inner payload is a DM channel or
 // guild channel object.
 const ChannelCreate = "CHANNEL_CREATE"
@@ -145,7 +156,15 @@ const GuildMemberUpdate = "GUILD_MEMBER_UPDATE"
 //  Fields:
 //  - GuildID Snowflake
 //  - Members []*Member
-const GuildMembersChunk = "GUILD_MEMBER_CHUNK"
+const GuildMembersChunk = "GUILD_MEMBERS_CHUNK"
+
+// GuildMembersLoaded Sent by Disgord once the offline members of a large guild were requested and cached, see
+// CacheConfig.LoadOfflineMembers. The cached member list is complete unless Err is set. This is not a Discord event.
+//  Fields:
+//  - GuildID     Snowflake
+//  - MemberCount int
+//  - Err         error
+const GuildMembersLoaded = "GUILD_MEMBERS_LOADED"
 
 // GuildRoleCreate Sent when a guild role is created.
 //  Fields:
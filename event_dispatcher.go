@@ -1,5 +1,15 @@
 package disgord
 
+import "context"
+
+// Middleware runs before the handlers and channels of an event. The returned event box is passed on to the next
+// middleware, and finally the handlers. It may be changed, but must keep its type. Return nil to stop the event
+// from reaching the handlers, such as to ignore messages from bots.
+//
+// Middlewares run one event at a time on the goroutine that dispatches the events of every shard, unlike the
+// handlers, so they must return quickly. Slow work, such as REST calls, belongs in the handlers.
+type Middleware func(session Session, evtName string, box interface{}) interface{}
+
 // On ... TODO
 func (d *Dispatch) On(event string, handlers ...interface{}) {
 	d.ws.RegisterEvent(event)
@@ -25,6 +35,46 @@ func (d *Dispatch) Once(event string, handlers ...interface{}) {
 	}
 }
 
+// Use adds middlewares that run before the handlers of every event. Middlewares run in the order they were added,
+// and before the middlewares of the specific event. See Middleware.
+func (d *Dispatch) Use(middlewares ...Middleware) {
+	d.listenersLock.Lock()
+	defer d.listenersLock.Unlock()
+
+	d.middlewares = append(d.middlewares, middlewares...)
+}
+
+// UseOn adds middlewares that run before the handlers of the given event. See Middleware.
+func (d *Dispatch) UseOn(event string, middlewares ...Middleware) {
+	d.listenersLock.Lock()
+	defer d.listenersLock.Unlock()
+
+	if d.eventMiddlewares == nil {
+		d.eventMiddlewares = make(map[string][]Middleware)
+	}
+	d.eventMiddlewares[event] = append(d.eventMiddlewares[event], middlewares...)
+}
+
+// dispatch passes the event through the middlewares, and hands it to the waiters, channels and handlers unless a
+// middleware stopped the event
+func (d *Dispatch) dispatch(ctx context.Context, evtName string, session Session, box interface{}) {
+	d.listenersLock.RLock()
+	middlewares := make([]Middleware, 0, len(d.middlewares)+len(d.eventMiddlewares[evtName]))
+	middlewares = append(middlewares, d.middlewares...)
+	middlewares = append(middlewares, d.eventMiddlewares[evtName]...)
+	d.listenersLock.RUnlock()
+
+	for _, middleware := range middlewares {
+		if box = middleware(session, evtName, box); box == nil {
+			return
+		}
+	}
+
+	d.triggerChan(ctx, evtName, session, box)
+	d.waiters.deliver(evtName, box)
+	go d.triggerCallbacks(ctx, evtName, session, box)
+}
+
 func (d *Dispatch) start() {
 	// make sure every channel has a receiver to avoid deadlock
 	// TODO: review, this feels hacky
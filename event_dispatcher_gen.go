@@ -7,13 +7,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/andersfylling/disgord/websocket"
 	"sync"
 )
 
 // NewDispatch construct a Dispatch object for reacting to web socket events
 // from discord
-func NewDispatch(ws *websocket.Client) *Dispatch {
+func NewDispatch(ws DiscordWebsocket) *Dispatch {
 	dispatcher := &Dispatch{
 		allChan: make(chan interface{}),
 
@@ -21,6 +20,7 @@ func NewDispatch(ws *websocket.Client) *Dispatch {
 		channelDeleteChan:            make(chan *ChannelDelete),
 		channelPinsUpdateChan:        make(chan *ChannelPinsUpdate),
 		channelUpdateChan:            make(chan *ChannelUpdate),
+		connectionStateChangeChan:    make(chan *ConnectionStateChange),
 		guildBanAddChan:              make(chan *GuildBanAdd),
 		guildBanRemoveChan:           make(chan *GuildBanRemove),
 		guildCreateChan:              make(chan *GuildCreate),
@@ -31,6 +31,7 @@ func NewDispatch(ws *websocket.Client) *Dispatch {
 		guildMemberRemoveChan:        make(chan *GuildMemberRemove),
 		guildMemberUpdateChan:        make(chan *GuildMemberUpdate),
 		guildMembersChunkChan:        make(chan *GuildMembersChunk),
+		guildMembersLoadedChan:       make(chan *GuildMembersLoaded),
 		guildRoleCreateChan:          make(chan *GuildRoleCreate),
 		guildRoleDeleteChan:          make(chan *GuildRoleDelete),
 		guildRoleUpdateChan:          make(chan *GuildRoleUpdate),
@@ -72,6 +73,7 @@ type Dispatch struct {
 	channelDeleteChan            chan *ChannelDelete
 	channelPinsUpdateChan        chan *ChannelPinsUpdate
 	channelUpdateChan            chan *ChannelUpdate
+	connectionStateChangeChan    chan *ConnectionStateChange
 	guildBanAddChan              chan *GuildBanAdd
 	guildBanRemoveChan           chan *GuildBanRemove
 	guildCreateChan              chan *GuildCreate
@@ -82,6 +84,7 @@ type Dispatch struct {
 	guildMemberRemoveChan        chan *GuildMemberRemove
 	guildMemberUpdateChan        chan *GuildMemberUpdate
 	guildMembersChunkChan        chan *GuildMembersChunk
+	guildMembersLoadedChan       chan *GuildMembersLoaded
 	guildRoleCreateChan          chan *GuildRoleCreate
 	guildRoleDeleteChan          chan *GuildRoleDelete
 	guildRoleUpdateChan          chan *GuildRoleUpdate
@@ -103,11 +106,18 @@ type Dispatch struct {
 	voiceStateUpdateChan         chan *VoiceStateUpdate
 	webhooksUpdateChan           chan *WebhooksUpdate
 
-	ws *websocket.Client
+	ws DiscordWebsocket
 
 	listeners      map[string][]interface{}
 	listenOnceOnly map[string][]int
 
+	// middlewares run before the listeners of every event, eventMiddlewares before the listeners of one event
+	middlewares      []Middleware
+	eventMiddlewares map[string][]Middleware
+
+	// waiters receive the first event matching their predicate, see Client.WaitFor
+	waiters eventWaiters
+
 	shutdown chan struct{}
 
 	listenersLock sync.RWMutex
@@ -125,6 +135,8 @@ func (d *Dispatch) EventChan(event string) (channel interface{}, err error) {
 		channel = d.ChannelPinsUpdate()
 	case EventChannelUpdate:
 		channel = d.ChannelUpdate()
+	case EventConnectionStateChange:
+		channel = d.ConnectionStateChange()
 	case EventGuildBanAdd:
 		channel = d.GuildBanAdd()
 	case EventGuildBanRemove:
@@ -145,6 +157,8 @@ func (d *Dispatch) EventChan(event string) (channel interface{}, err error) {
 		channel = d.GuildMemberUpdate()
 	case EventGuildMembersChunk:
 		channel = d.GuildMembersChunk()
+	case EventGuildMembersLoaded:
+		channel = d.GuildMembersLoaded()
 	case EventGuildRoleCreate:
 		channel = d.GuildRoleCreate()
 	case EventGuildRoleDelete:
@@ -204,6 +218,7 @@ func (d *Dispatch) alwaysListenToChans() {
 			case <-d.channelDeleteChan:
 			case <-d.channelPinsUpdateChan:
 			case <-d.channelUpdateChan:
+			case <-d.connectionStateChangeChan:
 			case <-d.guildBanAddChan:
 			case <-d.guildBanRemoveChan:
 			case <-d.guildCreateChan:
@@ -214,6 +229,7 @@ func (d *Dispatch) alwaysListenToChans() {
 			case <-d.guildMemberRemoveChan:
 			case <-d.guildMemberUpdateChan:
 			case <-d.guildMembersChunkChan:
+			case <-d.guildMembersLoadedChan:
 			case <-d.guildRoleCreateChan:
 			case <-d.guildRoleDeleteChan:
 			case <-d.guildRoleUpdateChan:
@@ -258,6 +274,8 @@ func (d *Dispatch) triggerChan(ctx context.Context, evtName string, session Sess
 		d.channelPinsUpdateChan <- box.(*ChannelPinsUpdate)
 	case EventChannelUpdate:
 		d.channelUpdateChan <- box.(*ChannelUpdate)
+	case EventConnectionStateChange:
+		d.connectionStateChangeChan <- box.(*ConnectionStateChange)
 	case EventGuildBanAdd:
 		d.guildBanAddChan <- box.(*GuildBanAdd)
 	case EventGuildBanRemove:
@@ -278,6 +296,8 @@ func (d *Dispatch) triggerChan(ctx context.Context, evtName string, session Sess
 		d.guildMemberUpdateChan <- box.(*GuildMemberUpdate)
 	case EventGuildMembersChunk:
 		d.guildMembersChunkChan <- box.(*GuildMembersChunk)
+	case EventGuildMembersLoaded:
+		d.guildMembersLoadedChan <- box.(*GuildMembersLoaded)
 	case EventGuildRoleCreate:
 		d.guildRoleCreateChan <- box.(*GuildRoleCreate)
 	case EventGuildRoleDelete:
@@ -342,6 +362,10 @@ func (d *Dispatch) triggerCallbacks(ctx context.Context, evtName string, session
 		for _, listener := range d.listeners[EventChannelUpdate] {
 			(listener.(ChannelUpdateCallback))(session, box.(*ChannelUpdate))
 		}
+	case EventConnectionStateChange:
+		for _, listener := range d.listeners[EventConnectionStateChange] {
+			(listener.(ConnectionStateChangeCallback))(session, box.(*ConnectionStateChange))
+		}
 	case EventGuildBanAdd:
 		for _, listener := range d.listeners[EventGuildBanAdd] {
 			(listener.(GuildBanAddCallback))(session, box.(*GuildBanAdd))
@@ -382,6 +406,10 @@ func (d *Dispatch) triggerCallbacks(ctx context.Context, evtName string, session
 		for _, listener := range d.listeners[EventGuildMembersChunk] {
 			(listener.(GuildMembersChunkCallback))(session, box.(*GuildMembersChunk))
 		}
+	case EventGuildMembersLoaded:
+		for _, listener := range d.listeners[EventGuildMembersLoaded] {
+			(listener.(GuildMembersLoadedCallback))(session, box.(*GuildMembersLoaded))
+		}
 	case EventGuildRoleCreate:
 		for _, listener := range d.listeners[EventGuildRoleCreate] {
 			(listener.(GuildRoleCreateCallback))(session, box.(*GuildRoleCreate))
@@ -507,6 +535,11 @@ func (d *Dispatch) ChannelUpdate() <-chan *ChannelUpdate {
 	return d.channelUpdateChan
 }
 
+// ConnectionStateChange gives access to connectionStateChangeChan for ConnectionStateChange events
+func (d *Dispatch) ConnectionStateChange() <-chan *ConnectionStateChange {
+	return d.connectionStateChangeChan
+}
+
 // GuildBanAdd gives access to guildBanAddChan for GuildBanAdd events
 func (d *Dispatch) GuildBanAdd() <-chan *GuildBanAdd {
 	return d.guildBanAddChan
@@ -557,6 +590,11 @@ func (d *Dispatch) GuildMembersChunk() <-chan *GuildMembersChunk {
 	return d.guildMembersChunkChan
 }
 
+// GuildMembersLoaded gives access to guildMembersLoadedChan for GuildMembersLoaded events
+func (d *Dispatch) GuildMembersLoaded() <-chan *GuildMembersLoaded {
+	return d.guildMembersLoadedChan
+}
+
 // GuildRoleCreate gives access to guildRoleCreateChan for GuildRoleCreate events
 func (d *Dispatch) GuildRoleCreate() <-chan *GuildRoleCreate {
 	return d.guildRoleCreateChan
@@ -0,0 +1,70 @@
+package disgord
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDispatch_middlewares(t *testing.T) {
+	d := NewDispatch(newTestShardManager(&ShardConfig{}))
+	d.start()
+	defer d.stop()
+
+	received := make(chan *MessageCreate, 10)
+	d.On(EventMessageCreate, func(session Session, evt *MessageCreate) {
+		received <- evt
+	})
+
+	var order []string
+	d.Use(func(session Session, evtName string, box interface{}) interface{} {
+		order = append(order, "global")
+		if msg, ok := box.(*MessageCreate); ok && msg.Message.Author.Bot {
+			return nil
+		}
+		return box
+	})
+	d.UseOn(EventMessageCreate, func(session Session, evtName string, box interface{}) interface{} {
+		order = append(order, "message")
+		if box.(*MessageCreate).Message.ChannelID != 1 {
+			return nil
+		}
+		return box
+	}, func(session Session, evtName string, box interface{}) interface{} {
+		box.(*MessageCreate).Message.Content = "changed"
+		return box
+	})
+	d.UseOn(EventGuildCreate, func(session Session, evtName string, box interface{}) interface{} {
+		t.Error("middleware of another event was run")
+		return box
+	})
+
+	dispatch := func(channelID Snowflake, bot bool) {
+		msg := NewMessage()
+		msg.ChannelID = channelID
+		msg.Author = NewUser()
+		msg.Author.Bot = bot
+		d.dispatch(context.Background(), EventMessageCreate, nil, &MessageCreate{Message: msg})
+	}
+
+	dispatch(1, true)
+	if len(order) != 1 || order[0] != "global" {
+		t.Errorf("expected only the global middleware to run, got %v", order)
+	}
+	dispatch(2, false)
+	dispatch(1, false)
+
+	select {
+	case evt := <-received:
+		if evt.Message.ChannelID != 1 || evt.Message.Content != "changed" {
+			t.Errorf("unexpected event %+v", evt.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the event to reach the handler")
+	}
+	select {
+	case evt := <-received:
+		t.Errorf("expected the other events to be dropped, got %+v", evt.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,122 @@
+package disgord
+
+import (
+	"context"
+	"sync"
+)
+
+// eventWaiter receives the event boxes accepted by its predicate. Matches are dropped while the match channel is
+// full, such that a waiter without room only keeps the first match.
+type eventWaiter struct {
+	event     string
+	predicate func(box interface{}) bool
+	match     chan interface{}
+}
+
+// eventWaiters holds the waiters of every event
+type eventWaiters struct {
+	sync.Mutex
+	waiters map[string][]*eventWaiter
+}
+
+// add starts delivering events to the waiter. register is called for the first waiter of an event.
+func (w *eventWaiters) add(waiter *eventWaiter, register func(event string)) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.waiters == nil {
+		w.waiters = make(map[string][]*eventWaiter)
+	}
+	if len(w.waiters[waiter.event]) == 0 {
+		register(waiter.event)
+	}
+	w.waiters[waiter.event] = append(w.waiters[waiter.event], waiter)
+}
+
+// remove stops delivering events to the waiter. unregister is called once the last waiter of an event is removed.
+func (w *eventWaiters) remove(waiter *eventWaiter, unregister func(event string)) {
+	w.Lock()
+	defer w.Unlock()
+
+	waiters := w.waiters[waiter.event]
+	for i := range waiters {
+		if waiters[i] == waiter {
+			waiters = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) > 0 {
+		w.waiters[waiter.event] = waiters
+		return
+	}
+
+	delete(w.waiters, waiter.event)
+	unregister(waiter.event)
+}
+
+// deliver passes the event box to every waiter whose predicate accepts it. The predicates are run without the
+// lock held, so they may use the session.
+func (w *eventWaiters) deliver(evtName string, box interface{}) {
+	w.Lock()
+	waiters := w.waiters[evtName]
+	w.Unlock()
+
+	for _, waiter := range waiters {
+		if !waiter.predicate(box) {
+			continue
+		}
+		select {
+		case waiter.match <- box:
+		default:
+		}
+	}
+}
+
+// WaitFor waits for the first event that the predicate accepts, and returns its event box. The box has the type
+// of the event handlers, such as *MessageCreate for EventMessageCreate. A nil predicate accepts any event. The
+// event is registered for the duration of the wait, if no handler has registered it already. The context error
+// is returned if the context is done before a matching event is received.
+//
+// Predicates run before the handlers of the event, after the middlewares, and must return quickly:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	box, err := session.WaitFor(ctx, disgord.EventMessageCreate, func(box interface{}) bool {
+//		msg := box.(*disgord.MessageCreate).Message
+//		return msg.Author.ID == userID && msg.ChannelID == channelID
+//	})
+func (c *Client) WaitFor(ctx context.Context, event string, predicate func(box interface{}) bool) (box interface{}, err error) {
+	if predicate == nil {
+		predicate = func(interface{}) bool {
+			return true
+		}
+	}
+
+	waiter := c.addWaiter(event, predicate, 1)
+	defer c.removeWaiter(waiter)
+
+	select {
+	case box = <-waiter.match:
+		return box, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// addWaiter starts delivering the matching event boxes to a new waiter, and registers the event at the socket
+// layer for the waiters. buffer is the number of matches kept until they are received.
+func (c *Client) addWaiter(event string, predicate func(box interface{}) bool, buffer int) *eventWaiter {
+	waiter := &eventWaiter{
+		event:     event,
+		predicate: predicate,
+		match:     make(chan interface{}, buffer),
+	}
+	c.evtDispatch.waiters.add(waiter, c.shardMngr.RegisterEvent)
+	return waiter
+}
+
+// removeWaiter stops delivering event boxes to the waiter, and removes the registration of the waiters once
+// nobody waits for the event. The event stays registered for handlers, channels and AcceptEvent.
+func (c *Client) removeWaiter(waiter *eventWaiter) {
+	c.evtDispatch.waiters.remove(waiter, c.shardMngr.RemoveEvent)
+}
@@ -0,0 +1,80 @@
+package disgord
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClient_WaitFor(t *testing.T) {
+	mngr := newTestShardManager(&ShardConfig{})
+	c := &Client{
+		shardMngr:   mngr,
+		evtDispatch: NewDispatch(mngr),
+	}
+	// handlers must be added before events are dispatched, as the listeners are read without locking
+	c.On(EventMessageReactionAdd, func(session Session, evt *MessageReactionAdd) {})
+	c.evtDispatch.start()
+	defer c.evtDispatch.stop()
+
+	message := func(channelID Snowflake) *MessageCreate {
+		msg := NewMessage()
+		msg.ChannelID = channelID
+		return &MessageCreate{Message: msg}
+	}
+
+	t.Run("match", func(t *testing.T) {
+		go func() {
+			// wait for the waiter to be added
+			for !mngr.tracksEvent(EventMessageCreate) {
+				time.Sleep(time.Millisecond)
+			}
+			c.evtDispatch.dispatch(context.Background(), EventMessageCreate, c, message(2))
+			c.evtDispatch.dispatch(context.Background(), EventMessageCreate, c, message(1))
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		box, err := c.WaitFor(ctx, EventMessageCreate, func(box interface{}) bool {
+			return box.(*MessageCreate).Message.ChannelID == 1
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt, ok := box.(*MessageCreate); !ok || evt.Message.ChannelID != 1 {
+			t.Errorf("expected the matching message, got %+v", box)
+		}
+		if mngr.tracksEvent(EventMessageCreate) {
+			t.Error("expected the event to be removed once nobody waits for it")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := c.WaitFor(ctx, EventMessageReactionAdd, nil); err != context.DeadlineExceeded {
+			t.Errorf("expected the deadline to be exceeded, got %v", err)
+		}
+		if !mngr.tracksEvent(EventMessageReactionAdd) {
+			t.Error("expected the event of the handler to stay registered")
+		}
+	})
+
+	t.Run("accepted during the wait", func(t *testing.T) {
+		go func() {
+			for !mngr.tracksEvent(EventTypingStart) {
+				time.Sleep(time.Millisecond)
+			}
+			c.AcceptEvent(EventTypingStart)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := c.WaitFor(ctx, EventTypingStart, nil); err != context.DeadlineExceeded {
+			t.Errorf("expected the deadline to be exceeded, got %v", err)
+		}
+		if !mngr.tracksEvent(EventTypingStart) {
+			t.Error("expected the accepted event to stay registered")
+		}
+	})
+}
@@ -5,6 +5,9 @@ package disgord
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/andersfylling/disgord/websocket"
 )
 
 type eventBox interface {
@@ -64,6 +67,23 @@ type Resumed struct {
 
 // ---------------------------
 
+// ConnectionStateChange the gateway connection of a shard changed state
+type ConnectionStateChange struct {
+	ShardID uint                      `json:"shard_id"`
+	From    websocket.ConnectionState `json:"from"`
+	To      websocket.ConnectionState `json:"to"`
+
+	// Attempt and Delay are set when reconnecting, see websocket.ReconnectPolicy
+	Attempt uint          `json:"attempt"`
+	Delay   time.Duration `json:"delay"`
+
+	// Reason is the error that caused the change, if any
+	Reason string          `json:"reason"`
+	Ctx    context.Context `json:"-"`
+}
+
+// ---------------------------
+
 // ChannelCreate new channel created
 type ChannelCreate struct {
 	Channel *Channel        `json:"channel"`
@@ -324,9 +344,24 @@ type GuildMemberUpdate struct {
 
 // GuildMembersChunk response to Request Guild Members
 type GuildMembersChunk struct {
-	GuildID Snowflake       `json:"guild_id"`
-	Members []*Member       `json:"members"`
-	Ctx     context.Context `json:"-"`
+	GuildID    Snowflake       `json:"guild_id"`
+	Members    []*Member       `json:"members"`
+	ChunkIndex uint            `json:"chunk_index"`
+	ChunkCount uint            `json:"chunk_count"`
+	NotFound   []Snowflake     `json:"not_found"`
+	Presences  []*UserPresence `json:"presences"`
+	Nonce      string          `json:"nonce"`
+	Ctx        context.Context `json:"-"`
+}
+
+// ---------------------------
+
+// GuildMembersLoaded the offline members of a large guild were requested and cached
+type GuildMembersLoaded struct {
+	GuildID     Snowflake       `json:"guild_id"`
+	MemberCount int             `json:"member_count"`
+	Err         error           `json:"-"`
+	Ctx         context.Context `json:"-"`
 }
 
 // ---------------------------
@@ -58,6 +58,25 @@ type ChannelUpdateCallback = func(session Session, h *ChannelUpdate)
 
 // ---------------------------
 
+// EventConnectionStateChange Sent by Disgord when the gateway connection of a shard changes state, such as when it
+// is lost and reconnecting. This is not a Discord event.
+//  Fields:
+//  - ShardID uint
+//  - From    websocket.ConnectionState
+//  - To      websocket.ConnectionState
+//  - Attempt uint
+//  - Delay   time.Duration
+//  - Reason  string
+//
+const EventConnectionStateChange = event.ConnectionStateChange
+
+func (h *ConnectionStateChange) registerContext(ctx context.Context) { h.Ctx = ctx }
+
+// ConnectionStateChangeCallback is triggered in ConnectionStateChange events
+type ConnectionStateChangeCallback = func(session Session, h *ConnectionStateChange)
+
+// ---------------------------
+
 // EventGuildBanAdd Sent when a user is banned from a guild. The inner payload is a user object, with an extra guild_id key.
 //
 const EventGuildBanAdd = event.GuildBanAdd
@@ -194,6 +213,22 @@ type GuildMembersChunkCallback = func(session Session, h *GuildMembersChunk)
 
 // ---------------------------
 
+// EventGuildMembersLoaded Sent by Disgord once the offline members of a large guild were requested and cached, see
+// CacheConfig.LoadOfflineMembers. The cached member list is complete unless Err is set. This is not a Discord event.
+//  Fields:
+//  - GuildID     Snowflake
+//  - MemberCount int
+//  - Err         error
+//
+const EventGuildMembersLoaded = event.GuildMembersLoaded
+
+func (h *GuildMembersLoaded) registerContext(ctx context.Context) { h.Ctx = ctx }
+
+// GuildMembersLoadedCallback is triggered in GuildMembersLoaded events
+type GuildMembersLoadedCallback = func(session Session, h *GuildMembersLoaded)
+
+// ---------------------------
+
 // EventGuildRoleCreate Sent when a guild role is created.
 //  Fields:
 //  - GuildID   Snowflake
@@ -41,6 +41,13 @@ type Dispatch struct {
 	listeners      map[string][]interface{}
 	listenOnceOnly map[string][]int
 
+	// middlewares run before the listeners of every event, eventMiddlewares before the listeners of one event
+	middlewares      []Middleware
+	eventMiddlewares map[string][]Middleware
+
+	// waiters receive the first event matching their predicate, see Client.WaitFor
+	waiters eventWaiters
+
 	shutdown chan struct{}
 
 	listenersLock sync.RWMutex
@@ -1,20 +1,41 @@
 module github.com/andersfylling/disgord
 
+go 1.27.1
+
 require (
-	github.com/alecthomas/gometalinter v2.0.11+incompatible // indirect
 	github.com/andersfylling/snowflake/v2 v2.0.3
-	github.com/fatih/gomodifytags v0.0.0-20180914191908-141225bf62b6 // indirect
 	github.com/gorilla/websocket v1.4.0
 	github.com/json-iterator/go v1.1.5
+	github.com/sergi/go-diff v1.0.0
+	github.com/sirupsen/logrus v1.1.1
+	golang.org/x/crypto v0.0.0-20181009213950-7c1a557ab941
+)
+
+require (
+	9fans.net/go v0.0.0-20180727211846-5d4fa602e1e8 // indirect
+	github.com/alecthomas/gometalinter v2.0.11+incompatible // indirect
+	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fatih/camelcase v1.0.0 // indirect
+	github.com/fatih/gomodifytags v0.0.0-20180914191908-141225bf62b6 // indirect
+	github.com/fatih/structtag v1.0.0 // indirect
+	github.com/google/shlex v0.0.0-20150127133951-6f45313302b9 // indirect
 	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/mdempsky/gocode v0.0.0-20180727200127-00e7f5ac290a // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
+	github.com/nicksnyder/go-i18n v1.10.0 // indirect
+	github.com/pelletier/go-toml v1.2.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rogpeppe/godef v1.0.0 // indirect
-	github.com/sergi/go-diff v1.0.0
-	github.com/sirupsen/logrus v1.1.1
+	github.com/sqs/goreturns v0.0.0-20180302073349-83e02874ec12 // indirect
+	github.com/stretchr/testify v1.2.2 // indirect
+	github.com/tpng/gopkgs v0.0.0-20180428091733-81e90e22e204 // indirect
+	github.com/zmb3/goaddimport v0.0.0-20170810013102-4ab94a07ab86 // indirect
 	github.com/zmb3/gogetdoc v0.0.0-20181009153131-0d07153cccef // indirect
-	golang.org/x/crypto v0.0.0-20181009213950-7c1a557ab941 // indirect
 	golang.org/x/sys v0.0.0-20181005133103-4497e2df6f9e // indirect
 	golang.org/x/tools v0.0.0-20181010000725-29f11e2b93f4 // indirect
+	gopkg.in/alecthomas/kingpin.v3-unstable v3.0.0-20180810215634-df19058c872c // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v2 v2.2.1 // indirect
 )
@@ -3,6 +3,7 @@ package httd
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -117,7 +118,7 @@ func NewClient(conf *Config) *Client {
 		panic(fmt.Sprintf("Discord API version %d is not supported", conf.APIVersion))
 	}
 
-	if conf.BotToken == "" {
+	if conf.BotToken == "" && !conf.WithoutAuthorization {
 		panic("No Discord Bot Token was provided")
 	}
 
@@ -136,19 +137,31 @@ func NewClient(conf *Config) *Client {
 	}
 
 	// setup the required http request header fields
-	authorization := fmt.Sprintf(AuthorizationFormat, conf.BotToken)
 	userAgent := fmt.Sprintf(UserAgentFormat, conf.UserAgentSourceURL, conf.UserAgentVersion, conf.UserAgentExtra)
 	header := map[string][]string{
-		"Authorization":   {authorization},
 		"User-Agent":      {userAgent},
 		"Accept-Encoding": {"gzip"},
 	}
+	if !conf.WithoutAuthorization {
+		header["Authorization"] = []string{fmt.Sprintf(AuthorizationFormat, conf.BotToken)}
+	}
+
+	retryPolicy := DefaultRetryPolicy
+	if conf.RetryPolicy != nil {
+		retryPolicy = *conf.RetryPolicy
+	}
+
+	rateLimiter := conf.RateLimiter
+	if rateLimiter == nil {
+		rateLimiter = NewRateLimit()
+	}
 
 	return &Client{
-		url:        BaseURL + "/v" + strconv.Itoa(conf.APIVersion),
-		reqHeader:  header,
-		httpClient: conf.HTTPClient,
-		rateLimit:  NewRateLimit(),
+		url:         BaseURL + "/v" + strconv.Itoa(conf.APIVersion),
+		reqHeader:   header,
+		httpClient:  conf.HTTPClient,
+		rateLimit:   rateLimiter,
+		retryPolicy: retryPolicy,
 	}
 }
 
@@ -158,10 +171,22 @@ type Config struct {
 	APIVersion int
 	BotToken   string
 
+	// WithoutAuthorization sends the requests without a bot token. Only endpoints that carry their own token,
+	// such as executing a webhook, can be used.
+	WithoutAuthorization bool
+
 	HTTPClient *http.Client
 
 	CancelRequestWhenRateLimited bool
 
+	// RateLimiter keeps track of the rate limits. Defaults to an in memory rate limiter, see NewRateLimit. Bots
+	// running several processes with the same token should share their rate limits, see NewDistributedRateLimit.
+	RateLimiter RateLimiter
+
+	// RetryPolicy decides when requests that were rate limited or failed with a server error are sent again.
+	// Defaults to DefaultRetryPolicy.
+	RetryPolicy *RetryPolicy
+
 	// Header field: `User-Agent: DiscordBot ({Source}, {Version}) {Extra}`
 	UserAgentVersion   string
 	UserAgentSourceURL string
@@ -178,20 +203,53 @@ type Details struct {
 
 // Request is populated before executing a Discord request to correctly generate a http request
 type Request struct {
+	// Ctx is honoured while waiting for rate limits and during the http round trip. If nil, the context bound to
+	// the client by Client.WithContext is used.
+	Ctx context.Context
+
 	Method      string
 	Ratelimiter string
 	Endpoint    string
 	Body        interface{} // will automatically marshal to JSON if the ContentType is httd.ContentTypeJSON
 	ContentType string
+
+	// RetryPolicy overrides the retry policy of the client for this request
+	RetryPolicy *RetryPolicy
 }
 
 // Client is the httd client for handling Discord requests
 type Client struct {
 	url                          string // base url with API version
-	rateLimit                    *RateLimit
+	rateLimit                    RateLimiter
 	reqHeader                    http.Header
 	httpClient                   *http.Client
 	cancelRequestWhenRateLimited bool
+	retryPolicy                  RetryPolicy
+
+	ctx context.Context
+}
+
+// WithContext returns a shallow copy of the client where every request is bound to the given context, unless
+// the request specifies its own. The copy shares rate limits with the original client.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+// context returns the context the request should be bound to
+func (c *Client) context(r *Request) context.Context {
+	if r.Ctx != nil {
+		return r.Ctx
+	}
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
 }
 
 func (c *Client) decodeResponseBody(resp *http.Response) (body []byte, err error) {
@@ -231,7 +289,15 @@ func (c *Client) decodeResponseBody(resp *http.Response) (body []byte, err error
 //
 // The client.config.CancelRequestWhenRateLimited forces an error if a rate limit is encountered, regardless of the
 // Client.Timeout value.
+//
+// The wait is aborted with the context error if the request context is done, or if its deadline expires before
+// the rate limit is reset.
 func WaitIfRateLimited(c *Client, r *Request) (waited bool, err error) {
+	ctx := c.context(r)
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	deadtime := c.RateLimiter().WaitTime(r)
 	if deadtime.Nanoseconds() > 0 {
 		if c.cancelRequestWhenRateLimited {
@@ -243,46 +309,100 @@ func WaitIfRateLimited(c *Client, r *Request) (waited bool, err error) {
 			err = errors.New("rate limit timeout is higher than http.Client.Timeout, cannot wait")
 			return
 		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(deadtime)) {
+			err = context.DeadlineExceeded
+			return
+		}
 
-		<-time.After(deadtime)
+		select {
+		case <-time.After(deadtime):
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
 	}
 
 	waited = true
 	return
 }
 
-// Request execute a Discord request
+// Request execute a Discord request. Requests that are rate limited or fail with a server error are retried
+// according to the retry policy of the request, or the client if the request does not specify one.
 func (c *Client) Request(r *Request) (resp *http.Response, body []byte, err error) {
-	var bodyReader io.Reader
+	// the body is kept in memory such that it can be sent again on retries
+	var content []byte
 	if r.Body != nil {
 		switch b := r.Body.(type) { // Determine the type of the passed body so we can treat it differently
 		case io.Reader:
-			bodyReader = b
+			content, err = ioutil.ReadAll(b)
 		default:
 			// If the type is unknown, possibly Marshal it as JSON
 			if r.ContentType != ContentTypeJSON {
 				return nil, nil, errors.New("unknown request body types and only be used in conjunction with httd.ContentTypeJSON")
 			}
 
-			bodyReader, err = convertStructToIOReader(r.Body)
-			if err != nil {
-				return
-			}
+			content, err = json.Marshal(r.Body)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	policy := &c.retryPolicy
+	if r.RetryPolicy != nil {
+		policy = r.RetryPolicy
+	}
+
+	for retry := uint(0); ; retry++ {
+		var bodyReader io.Reader
+		if content != nil {
+			bodyReader = bytes.NewReader(content)
+		}
+
+		resp, body, err = c.do(r, bodyReader)
+		if err == nil {
+			return
+		}
+
+		wait, ok := policy.retryAfter(r.Method, resp, body, retry)
+		if !ok || (RateLimited(resp) && !c.waitForRateLimit(wait)) {
+			return
+		}
+		if e := sleep(c.context(r), wait); e != nil {
+			err = e
+			return
 		}
 	}
+}
 
+// do sends the request once
+func (c *Client) do(r *Request, bodyReader io.Reader) (resp *http.Response, body []byte, err error) {
 	// check the rate limiter for how long we must wait before sending the request
 	_, err = WaitIfRateLimited(c, r)
 	if err != nil {
 		return
 	}
 
+	// wait in line for a free slot in the bucket
+	limiter, queued := c.RateLimiter().(BucketRateLimiter)
+	if queued {
+		var release func()
+		if release, err = limiter.Reserve(c.context(r), r); err != nil {
+			return
+		}
+		defer release()
+	}
+
 	// create request
 	req, err := http.NewRequest(r.Method, c.url+r.Endpoint, bodyReader)
 	if err != nil {
 		return
 	}
-	req.Header = c.reqHeader
+	req = req.WithContext(c.context(r))
+	req.Header = make(http.Header, len(c.reqHeader)+1)
+	for key, values := range c.reqHeader {
+		req.Header[key] = values
+	}
 	req.Header.Set(ContentType, r.ContentType) // unique for each request
 
 	// send request
@@ -294,7 +414,13 @@ func (c *Client) Request(r *Request) (resp *http.Response, body []byte, err erro
 	body, err = c.decodeResponseBody(resp)
 
 	// update rate limits
-	c.RateLimiter().UpdateRegisters(r.Ratelimiter, resp, body)
+	bucketKey := r.Ratelimiter
+	if queued {
+		limiter.UpdateBuckets(r, resp, body)
+		bucketKey = limiter.BucketKey(r)
+	} else {
+		c.RateLimiter().UpdateRegisters(bucketKey, resp, body)
+	}
 
 	// check if request was successful
 	noDiff := resp.StatusCode == http.StatusNotModified
@@ -302,10 +428,7 @@ func (c *Client) Request(r *Request) (resp *http.Response, body []byte, err erro
 	if !(noDiff || withinSuccessScope) {
 		// not within successful http range
 		// TODO: redirects?
-		msg := "response was not within the successful http code range [200, 300). code: "
-		msg += strconv.Itoa(resp.StatusCode) + ", response: "
-		msg += string(body)
-		err = errors.New(msg)
+		err = newErrorREST(r, bucketKey, resp, body)
 	}
 
 	return
@@ -315,14 +438,3 @@ func (c *Client) Request(r *Request) (resp *http.Response, body []byte, err erro
 func (c *Client) RateLimiter() RateLimiter {
 	return c.rateLimit
 }
-
-// helper functions
-func convertStructToIOReader(v interface{}) (io.Reader, error) {
-	jsonParamsBytes, err := json.Marshal(v)
-	if err != nil {
-		return nil, err
-	}
-	jsonParamsReader := bytes.NewReader(jsonParamsBytes)
-
-	return jsonParamsReader, nil
-}
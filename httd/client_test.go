@@ -3,9 +3,13 @@ package httd
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func missingImplError(t *testing.T, interfaceName string) {
@@ -34,6 +38,18 @@ func TestClientImplementInterfaces(t *testing.T) {
 	}
 }
 
+func TestNewClientWithoutAuthorization(t *testing.T) {
+	client := NewClient(&Config{
+		APIVersion:           6,
+		WithoutAuthorization: true,
+		UserAgentSourceURL:   "https://github.com/andersfylling/disgord",
+		UserAgentVersion:     "v0",
+	})
+	if _, exists := client.reqHeader["Authorization"]; exists {
+		t.Error("expected no Authorization header")
+	}
+}
+
 func TestDecodingResponseBody(t *testing.T) {
 	expected := "oashoasihdosado4o5ry8wy34hr8w3yr88y3r9283y"
 	client := &Client{}
@@ -91,3 +107,114 @@ func TestDecodingResponseBodyWithGZIP(t *testing.T) {
 	}
 
 }
+
+func newTestClient(url string) *Client {
+	return &Client{
+		url:        url,
+		rateLimit:  NewRateLimit(),
+		reqHeader:  http.Header{},
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	done := make(chan interface{})
+	defer close(done)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("bound to client", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		client := newTestClient(srv.URL).WithContext(ctx)
+		_, _, err := client.Get(&Request{Endpoint: "/slow"})
+		if err == nil {
+			t.Fatal("expected the request to be cancelled")
+		}
+	})
+
+	t.Run("bound to request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-time.After(50 * time.Millisecond)
+			cancel()
+		}()
+
+		_, _, err := newTestClient(srv.URL).Get(&Request{Ctx: ctx, Endpoint: "/slow"})
+		if err == nil {
+			t.Fatal("expected the request to be cancelled")
+		}
+	})
+}
+
+func TestWaitIfRateLimitedContext(t *testing.T) {
+	client := newTestClient("")
+	rateLimit := NewRateLimit()
+	client.rateLimit = rateLimit
+	now := rateLimit.TimeDiff.Now()
+	rateLimit.Bucket("b").update(&RateLimitInfo{
+		Reset: now.Add(5*time.Second).UnixNano() / int64(time.Millisecond),
+	}, now)
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-time.After(20 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err := WaitIfRateLimited(client, &Request{Ctx: ctx, Ratelimiter: "b"})
+		if err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("deadline before reset", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		_, err := WaitIfRateLimited(client, &Request{Ctx: ctx, Ratelimiter: "b"})
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+		if time.Since(start) > 100*time.Millisecond {
+			t.Error("waited for the rate limit even though the deadline would be exceeded")
+		}
+	})
+}
+
+// legacyRateLimiter only implements the RateLimiter interface
+type legacyRateLimiter struct {
+	RateLimiter
+}
+
+func TestRequestLegacyRateLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XRateLimitLimit, "5")
+		w.Header().Set(XRateLimitRemaining, "0")
+		w.Header().Set(XRateLimitReset, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		w.Header().Set(XRateLimitBucket, "abcd1234")
+	}))
+	defer srv.Close()
+
+	rateLimit := NewRateLimit()
+	client := newTestClient(srv.URL)
+	client.rateLimit = &legacyRateLimiter{rateLimit}
+	if _, _, err := client.Get(&Request{Endpoint: "/channels/1", Ratelimiter: "c:1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !rateLimit.RateLimited("c:1") {
+		t.Error("expected the bucket of the request key to be updated")
+	}
+	if len(rateLimit.hashes) != 0 {
+		t.Error("expected the bucket hash to be ignored")
+	}
+}
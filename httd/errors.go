@@ -0,0 +1,204 @@
+package httd
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON error codes returned by Discord.
+// See https://discordapp.com/developers/docs/topics/opcodes-and-status-codes#json-json-error-codes
+const (
+	ErrCodeUnknownAccount     = 10001
+	ErrCodeUnknownApplication = 10002
+	ErrCodeUnknownChannel     = 10003
+	ErrCodeUnknownGuild       = 10004
+	ErrCodeUnknownIntegration = 10005
+	ErrCodeUnknownInvite      = 10006
+	ErrCodeUnknownMember      = 10007
+	ErrCodeUnknownMessage     = 10008
+	ErrCodeUnknownOverwrite   = 10009
+	ErrCodeUnknownProvider    = 10010
+	ErrCodeUnknownRole        = 10011
+	ErrCodeUnknownToken       = 10012
+	ErrCodeUnknownUser        = 10013
+	ErrCodeUnknownEmoji       = 10014
+	ErrCodeUnknownWebhook     = 10015
+
+	ErrCodeUnauthorized               = 40001
+	ErrCodeMissingAccess              = 50001
+	ErrCodeInvalidAccountType         = 50002
+	ErrCodeCannotExecuteOnDMChannel   = 50003
+	ErrCodeCannotEditOthersMessage    = 50005
+	ErrCodeCannotSendEmptyMessage     = 50006
+	ErrCodeCannotSendMessagesToUser   = 50007
+	ErrCodeMissingPermissions         = 50013
+	ErrCodeInvalidAuthenticationToken = 50014
+	ErrCodeNoteTooLong                = 50015
+	ErrCodeInvalidBulkDeleteCount     = 50016
+	ErrCodeCannotPinInOtherChannel    = 50019
+	ErrCodeMessageTooOldToBulkDelete  = 50034
+	ErrCodeInvalidFormBody            = 50035
+	ErrCodeReactionBlocked            = 90001
+)
+
+// FieldError is a validation error for a single field in the request body
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorREST is returned when Discord responds with a http status code outside the range [200, 300). Use
+// errors.As, or the helpers IsNotFound, IsMissingPermissions, etc., to inspect it.
+type ErrorREST struct {
+	// HTTPCode is the http status code of the response
+	HTTPCode int
+
+	// Code is the Discord JSON error code. See the ErrCode constants. Zero if the response held none.
+	Code int
+
+	// Message is the error message given by Discord
+	Message string
+
+	// Errors holds the validation errors of the request body, keyed by the path of the field. Nested fields are
+	// separated by a dot, eg. "embed.fields.0.name".
+	Errors map[string][]FieldError
+
+	Method   string
+	Endpoint string
+
+	// Bucket is the key of the rate limit bucket the request was sent under. See RateLimiter.BucketKey.
+	Bucket string
+
+	// Body is the raw response body
+	Body []byte
+}
+
+func newErrorREST(r *Request, bucket string, resp *http.Response, body []byte) *ErrorREST {
+	e := &ErrorREST{
+		HTTPCode: resp.StatusCode,
+		Method:   r.Method,
+		Endpoint: r.Endpoint,
+		Bucket:   bucket,
+		Body:     body,
+	}
+
+	content := &struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Errors  json.RawMessage `json:"errors"`
+	}{}
+	if err := Unmarshal(body, content); err != nil {
+		// not json. Usually caused by a proxy or outage
+		return e
+	}
+
+	e.Code = content.Code
+	e.Message = content.Message
+	if len(content.Errors) > 0 {
+		e.Errors = make(map[string][]FieldError)
+		flattenFieldErrors(e.Errors, "", content.Errors)
+	}
+	return e
+}
+
+// flattenFieldErrors converts the nested error objects of Discord into a flat map keyed by the field path
+func flattenFieldErrors(errs map[string][]FieldError, path string, data json.RawMessage) {
+	fields := make(map[string]json.RawMessage)
+	if err := Unmarshal(data, &fields); err != nil {
+		return
+	}
+
+	for key, value := range fields {
+		if key == "_errors" {
+			var list []FieldError
+			if err := Unmarshal(value, &list); err == nil {
+				errs[path] = append(errs[path], list...)
+			}
+			continue
+		}
+
+		if path != "" {
+			key = path + "." + key
+		}
+		flattenFieldErrors(errs, key, value)
+	}
+}
+
+func (e *ErrorREST) Error() string {
+	msg := e.Method + " " + e.Endpoint + ": " + strconv.Itoa(e.HTTPCode) + " " + http.StatusText(e.HTTPCode)
+	if e.Message == "" {
+		if len(e.Body) > 0 {
+			msg += ": " + string(e.Body)
+		}
+		return msg
+	}
+
+	msg += ": " + e.Message + " (code " + strconv.Itoa(e.Code) + ")"
+	if len(e.Errors) > 0 {
+		fields := make([]string, 0, len(e.Errors))
+		for field := range e.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for i := range fields {
+			var msgs []string
+			for _, fieldErr := range e.Errors[fields[i]] {
+				msgs = append(msgs, fieldErr.Message)
+			}
+			fields[i] += ": " + strings.Join(msgs, ", ")
+		}
+		msg += " [" + strings.Join(fields, "; ") + "]"
+	}
+	return msg
+}
+
+// asErrorREST finds the first ErrorREST in the chain of wrapped errors, in the same manner as errors.As
+func asErrorREST(err error) (*ErrorREST, bool) {
+	for err != nil {
+		if e, ok := err.(*ErrorREST); ok {
+			return e, true
+		}
+
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// IsNotFound checks if the error was caused by a resource that does not exist, such as an unknown message
+func IsNotFound(err error) bool {
+	e, ok := asErrorREST(err)
+	return ok && e.HTTPCode == http.StatusNotFound
+}
+
+// IsMissingPermissions checks if the error was caused by the bot lacking permissions for the action
+func IsMissingPermissions(err error) bool {
+	e, ok := asErrorREST(err)
+	return ok && e.Code == ErrCodeMissingPermissions
+}
+
+// IsMissingAccess checks if the error was caused by the bot not having access to the resource, such as a
+// channel it cannot see
+func IsMissingAccess(err error) bool {
+	e, ok := asErrorREST(err)
+	return ok && e.Code == ErrCodeMissingAccess
+}
+
+// IsUnauthorized checks if the error was caused by an invalid bot token
+func IsUnauthorized(err error) bool {
+	e, ok := asErrorREST(err)
+	return ok && e.HTTPCode == http.StatusUnauthorized
+}
+
+// IsInvalidFormBody checks if the request body was rejected. See ErrorREST.Errors for the offending fields.
+func IsInvalidFormBody(err error) bool {
+	e, ok := asErrorREST(err)
+	return ok && e.Code == ErrCodeInvalidFormBody
+}
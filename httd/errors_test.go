@@ -0,0 +1,105 @@
+package httd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type wrappedError struct {
+	err error
+}
+
+func (e *wrappedError) Error() string { return "wrapped: " + e.err.Error() }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+func TestErrorREST(t *testing.T) {
+	responses := map[string]struct {
+		code int
+		body string
+	}{
+		"/permissions": {http.StatusForbidden, `{"code": 50013, "message": "Missing Permissions"}`},
+		"/unknown":     {http.StatusNotFound, `{"code": 10008, "message": "Unknown Message"}`},
+		"/form":        {http.StatusBadRequest, `{"code": 50035, "message": "Invalid Form Body", "errors": {"content": {"_errors": [{"code": "BASE_TYPE_MAX_LENGTH", "message": "Must be 2000 or fewer in length."}]}, "embed": {"fields": {"0": {"name": {"_errors": [{"code": "BASE_TYPE_REQUIRED", "message": "This field is required"}]}}}}}}`},
+		"/outage":      {http.StatusBadGateway, `<html>bad gateway</html>`},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[r.URL.Path]
+		w.WriteHeader(resp.code)
+		w.Write([]byte(resp.body))
+	}))
+	defer srv.Close()
+	client := newTestClient(srv.URL)
+
+	request := func(t *testing.T, endpoint string) *ErrorREST {
+		_, _, err := client.Get(&Request{Endpoint: endpoint, Ratelimiter: "bucket"})
+		e, ok := err.(*ErrorREST)
+		if !ok {
+			t.Fatalf("expected a *ErrorREST, got %T: %v", err, err)
+		}
+		if e.Endpoint != endpoint || e.Bucket != "bucket" || e.Method != http.MethodGet {
+			t.Errorf("request information is missing: %+v", e)
+		}
+		return e
+	}
+
+	t.Run("missing permissions", func(t *testing.T) {
+		e := request(t, "/permissions")
+		if e.HTTPCode != http.StatusForbidden || e.Code != ErrCodeMissingPermissions || e.Message != "Missing Permissions" {
+			t.Errorf("unexpected error content: %+v", e)
+		}
+		if !IsMissingPermissions(e) || IsNotFound(e) {
+			t.Error("helpers did not classify the error correctly")
+		}
+		if !IsMissingPermissions(&wrappedError{e}) {
+			t.Error("helpers must look through wrapped errors")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		e := request(t, "/unknown")
+		if e.Code != ErrCodeUnknownMessage {
+			t.Errorf("expected code %d, got %d", ErrCodeUnknownMessage, e.Code)
+		}
+		if !IsNotFound(&wrappedError{e}) || IsMissingPermissions(e) {
+			t.Error("helpers did not classify the error correctly")
+		}
+	})
+
+	t.Run("invalid form body", func(t *testing.T) {
+		e := request(t, "/form")
+		if !IsInvalidFormBody(e) {
+			t.Error("expected an invalid form body error")
+		}
+		if len(e.Errors) != 2 {
+			t.Fatalf("expected 2 field errors, got %+v", e.Errors)
+		}
+		if errs := e.Errors["content"]; len(errs) != 1 || errs[0].Code != "BASE_TYPE_MAX_LENGTH" {
+			t.Errorf("unexpected errors for content: %+v", errs)
+		}
+		if errs := e.Errors["embed.fields.0.name"]; len(errs) != 1 || errs[0].Code != "BASE_TYPE_REQUIRED" {
+			t.Errorf("unexpected errors for embed.fields.0.name: %+v", errs)
+		}
+
+		expected := "GET /form: 400 Bad Request: Invalid Form Body (code 50035) [content: Must be 2000 or fewer in length.; embed.fields.0.name: This field is required]"
+		if e.Error() != expected {
+			t.Errorf("unexpected error message.\nGot:  %s\nWant: %s", e.Error(), expected)
+		}
+	})
+
+	t.Run("non json body", func(t *testing.T) {
+		e := request(t, "/outage")
+		if e.HTTPCode != http.StatusBadGateway || e.Code != 0 {
+			t.Errorf("unexpected error content: %+v", e)
+		}
+		if string(e.Body) != responses["/outage"].body {
+			t.Errorf("expected the raw body to be kept, got %s", string(e.Body))
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		if IsNotFound(nil) || IsMissingPermissions(nil) {
+			t.Error("nil is not a REST error")
+		}
+	})
+}
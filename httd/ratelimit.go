@@ -1,9 +1,11 @@
 package httd
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,11 +16,12 @@ const (
 	XRateLimitRemaining  = "X-RateLimit-Remaining"
 	XRateLimitReset      = "X-RateLimit-Reset" // is converted from seconds to milliseconds!
 	XRateLimitGlobal     = "X-RateLimit-Global"
+	XRateLimitBucket     = "X-RateLimit-Bucket"
 	RateLimitRetryAfter  = "Retry-After"
 	GlobalRateLimiterKey = ""
 )
 
-// RateLimiter is the interface for the ratelimit manager
+// RateLimiter is the interface for the ratelimit manager. A rate limiter may also implement BucketRateLimiter.
 type RateLimiter interface {
 	Bucket(key string) *Bucket
 	RateLimitTimeout(key string) int64
@@ -27,6 +30,16 @@ type RateLimiter interface {
 	WaitTime(req *Request) time.Duration
 }
 
+// BucketRateLimiter is a RateLimiter which learns the buckets shared by several routes from the responses, and
+// queues the requests of each bucket. When the rate limiter of a Client implements it, a slot is reserved before
+// every request is sent and UpdateBuckets is called instead of UpdateRegisters.
+type BucketRateLimiter interface {
+	RateLimiter
+	BucketKey(req *Request) string
+	Reserve(ctx context.Context, req *Request) (release func(), err error)
+	UpdateBuckets(req *Request, res *http.Response, responseBody []byte)
+}
+
 type ratelimitBody struct {
 	Message    string `json:"message"`
 	RetryAfter int64  `json:"retry_after"`
@@ -164,6 +177,7 @@ func (d *DiscordTimeDiff) Now() (t time.Time) {
 func NewRateLimit() *RateLimit {
 	return &RateLimit{
 		buckets:  make(map[string]*Bucket),
+		hashes:   make(map[string]string),
 		global:   &Bucket{},
 		TimeDiff: NewDiscordTimeDiff(),
 	}
@@ -175,9 +189,74 @@ type RateLimit struct {
 	global   *Bucket
 	TimeDiff *DiscordTimeDiff
 
+	// hashes maps a route to the bucket hash given by Discord in the X-RateLimit-Bucket header
+	hashes map[string]string
+
 	mu sync.RWMutex
 }
 
+var _ BucketRateLimiter = (*RateLimit)(nil)
+
+// majorParameters are the top level resources Discord uses to separate rate limits
+var majorParameters = map[string]bool{
+	"channels": true,
+	"guilds":   true,
+	"webhooks": true,
+}
+
+// route identifies the rate limit route of a request as the http method and endpoint, where every id is
+// replaced by a placeholder. The major parameter is returned separately, as requests with the same route only
+// share a bucket when their major parameter is equal.
+func route(req *Request) (route, major string) {
+	path := req.Endpoint
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := range segments {
+		switch {
+		case i == 1 && majorParameters[segments[0]]:
+			major = segments[0] + "/" + segments[1]
+			segments[i] = "{major}"
+		case i == 2 && segments[0] == "webhooks":
+			// the webhook token is part of the major parameter
+			major += "/" + segments[2]
+			segments[i] = "{token}"
+		case i > 0 && segments[i-1] == "reactions":
+			segments[i] = "{emoji}"
+		case i > 0 && segments[i-1] == "invites":
+			segments[i] = "{code}"
+		case isSnowflake(segments[i]):
+			segments[i] = "{id}"
+		}
+	}
+
+	route = req.Method + " /" + strings.Join(segments, "/")
+	return
+}
+
+func isSnowflake(segment string) bool {
+	_, err := strconv.ParseUint(segment, 10, 64)
+	return err == nil
+}
+
+// BucketKey returns the key of the bucket the request is sent under. Once Discord has revealed the bucket of the
+// route, through the X-RateLimit-Bucket header, the key is the bucket hash combined with the major parameter,
+// such that every route in the same Discord bucket shares the rate limit. Until then, Request.Ratelimiter is used.
+func (r *RateLimit) BucketKey(req *Request) string {
+	route, major := route(req)
+
+	r.mu.RLock()
+	hash, exists := r.hashes[route]
+	r.mu.RUnlock()
+
+	if !exists {
+		return req.Ratelimiter
+	}
+	return hash + ":" + major
+}
+
 // Bucket returns a bucket given the key (or ID) for a rate limit bucket. If
 // no bucket exists for the key, one will be created.
 func (r *RateLimit) Bucket(key string) *Bucket {
@@ -186,9 +265,10 @@ func (r *RateLimit) Bucket(key string) *Bucket {
 
 	r.mu.Lock()
 	if bucket, exists = r.buckets[key]; !exists {
+		// the bucket is not limited until a response says otherwise. Using the local time as reset would
+		// limit the bucket until Discord's clock catches up, when Discord is behind.
 		r.buckets[key] = &Bucket{
 			endpoint: key,
-			reset:    r.TimeDiff.Now().UnixNano() / int64(time.Millisecond),
 		}
 		bucket = r.buckets[key]
 	}
@@ -225,16 +305,86 @@ func (r *RateLimit) RateLimited(key string) bool {
 // WaitTime get's the remaining time before another request can be made.
 // returns a time.Duration of milliseconds.
 func (r *RateLimit) WaitTime(req *Request) time.Duration {
+	key := r.BucketKey(req)
 	timeout := int64(0)
-	if r.RateLimited(req.Ratelimiter) {
-		timeout = r.RateLimitTimeout(req.Ratelimiter) // number of milliseconds
+	if r.RateLimited(key) {
+		timeout = r.RateLimitTimeout(key) // number of milliseconds
 	}
 
 	// Duration requires nano seconds argument, so multiply with millisecond
 	return time.Duration(timeout) * time.Millisecond
 }
 
-// UpdateRegisters updates the relevant buckets and time desync between the
+// Reserve waits in line until the bucket of the request has a free slot, and reserves it by decrementing the
+// remaining requests locally. Requests to the same bucket are handled in FIFO order. When the remaining requests
+// of the bucket are unknown, such as before the first response, requests are sent one at a time.
+//
+// release must be called once the response has been registered with UpdateBuckets.
+func (r *RateLimit) Reserve(ctx context.Context, req *Request) (release func(), err error) {
+	for {
+		key := r.BucketKey(req)
+		bucket := r.Bucket(key)
+		if err = bucket.enqueue(ctx); err != nil {
+			return
+		}
+
+		// the bucket of the route was discovered while waiting in line
+		if key != r.BucketKey(req) {
+			bucket.next()
+			continue
+		}
+
+		for {
+			wait, serialize := bucket.reserve(r.TimeDiff.Now())
+			if wait > 0 {
+				if err = sleep(ctx, wait); err != nil {
+					bucket.next()
+					return
+				}
+				continue
+			}
+
+			if serialize {
+				// hold the line until the response reveals the rate limit
+				var once sync.Once
+				return func() { once.Do(bucket.next) }, nil
+			}
+
+			bucket.next()
+			return func() {}, nil
+		}
+	}
+}
+
+// QueueDepths returns the number of requests waiting in line for each bucket that has a queue
+func (r *RateLimit) QueueDepths() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	depths := make(map[string]int)
+	for key, bucket := range r.buckets {
+		if depth := bucket.QueueDepth(); depth > 0 {
+			depths[key] = depth
+		}
+	}
+	return depths
+}
+
+// UpdateBuckets updates the relevant buckets and time desync between the
+// client and the Discord servers. The route of the request is moved to the bucket given by the response.
+func (r *RateLimit) UpdateBuckets(req *Request, resp *http.Response, content []byte) {
+	// learn which bucket the route belongs to
+	if hash := resp.Header.Get(XRateLimitBucket); hash != "" {
+		route, _ := route(req)
+		r.mu.Lock()
+		r.hashes[route] = hash
+		r.mu.Unlock()
+	}
+
+	r.UpdateRegisters(r.BucketKey(req), resp, content)
+}
+
+// UpdateRegisters updates the bucket of the key and time desync between the
 // client and the Discord servers.
 func (r *RateLimit) UpdateRegisters(key string, resp *http.Response, content []byte) {
 	// update time difference
@@ -249,10 +399,16 @@ func (r *RateLimit) UpdateRegisters(key string, resp *http.Response, content []b
 	}
 
 	// select bucket
-	// TODO: what if "key" is an endpoint with a global rate limiter only?
 	var bucket *Bucket
 	if info.Global {
 		bucket = r.global
+	} else if resp.Header.Get(XRateLimitRemaining) == "" && !RateLimited(resp) {
+		// the endpoint is only limited by the global rate limit
+		bucket = r.Bucket(key)
+		bucket.mu.Lock()
+		bucket.known = true
+		bucket.mu.Unlock()
+		return
 	} else {
 		bucket = r.Bucket(key)
 	}
@@ -271,14 +427,105 @@ type Bucket struct {
 	limit     uint64 // total allowed requests before rate limit
 	remaining uint64 // remaining requests
 	reset     int64  // unix milliseconds, even tho discord prefers seconds. global uses milliseconds however.
+	known     bool   // the rate limit has been given by a response. A limit of 0 means the global limit only
+
+	queue []chan struct{} // requests waiting for their turn
+	busy  bool            // the request first in line is being handled
 
 	mu sync.RWMutex
 }
 
+// QueueDepth returns the number of requests waiting in line for the bucket
+func (b *Bucket) QueueDepth() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.queue)
+}
+
+// enqueue blocks until the caller is first in line. The caller must call next to let the next request through.
+func (b *Bucket) enqueue(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.busy {
+		b.busy = true
+		b.mu.Unlock()
+		return nil
+	}
+	turn := make(chan struct{})
+	b.queue = append(b.queue, turn)
+	b.mu.Unlock()
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	for i := range b.queue {
+		if b.queue[i] == turn {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			b.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	b.mu.Unlock()
+
+	// the turn was given while the context was cancelled, so pass it on
+	b.next()
+	return ctx.Err()
+}
+
+// next lets the next request in line through
+func (b *Bucket) next() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.queue) == 0 {
+		b.busy = false
+		return
+	}
+
+	close(b.queue[0])
+	b.queue = b.queue[1:]
+}
+
+// reserve takes a slot from the bucket. If the bucket is exhausted the time until it resets is returned, and
+// serialize is true when the rate limit is unknown.
+func (b *Bucket) reserve(now time.Time) (wait time.Duration, serialize bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.known {
+		return 0, true
+	}
+
+	nowMilli := now.UnixNano() / int64(time.Millisecond)
+	if b.remaining == 0 && b.reset > nowMilli {
+		return time.Duration(b.reset-nowMilli) * time.Millisecond, false
+	}
+	if b.limit == 0 {
+		return 0, false
+	}
+
+	if b.remaining == 0 {
+		// the bucket has been reset since the last response
+		b.remaining = b.limit
+	}
+	b.remaining--
+	return 0, false
+}
+
 func (b *Bucket) update(info *RateLimitInfo, now time.Time) {
+	// responses within the same window may arrive out of order, and must not give back slots that have been
+	// reserved for requests still in flight
+	sameWindow := b.known && b.reset == info.Reset
+	if !sameWindow || uint64(info.Remaining) < b.remaining {
+		b.remaining = uint64(info.Remaining)
+	}
 	b.limit = uint64(info.Limit)
-	b.remaining = uint64(info.Remaining)
 	b.reset = info.Reset
+	b.known = true
 
 	retryAt := info.RetryAfter + (now.UnixNano() / int64(time.Millisecond))
 	if b.reset < retryAt {
@@ -0,0 +1,363 @@
+package httd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLease is how long a process may hold a bucket before other processes can take it over, in case the
+// process crashed.
+const DefaultLease = 10 * time.Second
+
+// RateLimitStore is a key value store shared by every process using the same bot token. See RedisStore.
+type RateLimitStore interface {
+	// Get returns the value of the key, and whether it exists
+	Get(key string) (value string, exists bool, err error)
+
+	// Set stores the value. A ttl of zero means the key never expires.
+	Set(key, value string, ttl time.Duration) error
+
+	// SetNX stores the value only if the key does not exist, and reports whether it was stored
+	SetNX(key, value string, ttl time.Duration) (ok bool, err error)
+
+	// SetIfEqual stores the value only if the key holds old, or does not exist when old is empty, and reports
+	// whether it was stored. The comparison and the write must be atomic.
+	SetIfEqual(key, old, value string, ttl time.Duration) (ok bool, err error)
+
+	// DeleteIfEqual deletes the key if it holds the given value
+	DeleteIfEqual(key, value string) error
+}
+
+// NewDistributedRateLimit creates a rate limiter which shares buckets and the global rate limit with every other
+// process using the same store and prefix.
+func NewDistributedRateLimit(store RateLimitStore, prefix string) *DistributedRateLimit {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &DistributedRateLimit{
+		store:        store,
+		prefix:       prefix,
+		id:           hex.EncodeToString(id),
+		hashes:       make(map[string]string),
+		queues:       NewRateLimit(),
+		Lease:        DefaultLease,
+		PollInterval: 50 * time.Millisecond,
+		TimeDiff:     NewDiscordTimeDiff(),
+	}
+}
+
+// DistributedRateLimit is a RateLimiter for bots running several processes with the same token. The state of
+// every bucket is kept in a RateLimitStore. A process must hold the lease of a bucket to reserve a slot in it,
+// and holds it while the request is in flight if the rate limit of the bucket is unknown. Leases expire, such
+// that a process which crashed does not hold the bucket forever.
+//
+// Within a process, requests to the same bucket are handled in FIFO order. Between processes the order is not
+// guaranteed.
+type DistributedRateLimit struct {
+	leases uint64 // first in the struct for 64-bit alignment of atomic operations
+
+	store  RateLimitStore
+	prefix string
+	id     string // identifies this process in leases
+
+	mu     sync.RWMutex
+	hashes map[string]string // cache of the bucket hashes in the store
+
+	// queues holds the local buckets used as FIFO queues
+	queues *RateLimit
+
+	// Lease is the maximum time a bucket is held by one process. It should be longer than the http timeout.
+	Lease time.Duration
+
+	// PollInterval is how often a bucket held by another process is checked
+	PollInterval time.Duration
+
+	TimeDiff *DiscordTimeDiff
+}
+
+var _ BucketRateLimiter = (*DistributedRateLimit)(nil)
+
+func (d *DistributedRateLimit) hashKey(route string) string {
+	return d.prefix + "hash:" + route
+}
+func (d *DistributedRateLimit) bucketKey(key string) string {
+	return d.prefix + "bucket:" + key
+}
+func (d *DistributedRateLimit) leaseKey(key string) string {
+	return d.prefix + "lease:" + key
+}
+func (d *DistributedRateLimit) globalKey() string {
+	return d.prefix + "global"
+}
+
+func (d *DistributedRateLimit) nowMilli() int64 {
+	return d.TimeDiff.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// stateTTL is how long the state of a bucket is kept in the store. Losing it only causes requests to be
+// serialized until the next response.
+func (d *DistributedRateLimit) stateTTL(reset int64) time.Duration {
+	ttl := time.Duration(reset-d.nowMilli())*time.Millisecond + time.Minute
+	if ttl < time.Minute {
+		ttl = time.Minute
+	}
+	return ttl
+}
+
+// encodeBucket serializes the rate limit of a bucket as "limit,remaining,reset"
+func encodeBucket(b *Bucket) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return strconv.FormatUint(b.limit, 10) + "," + strconv.FormatUint(b.remaining, 10) + "," + strconv.FormatInt(b.reset, 10)
+}
+
+func decodeBucket(key, value string) *Bucket {
+	bucket := &Bucket{endpoint: key}
+	fields := strings.Split(value, ",")
+	if len(fields) != 3 {
+		return bucket
+	}
+
+	var err1, err2, err3 error
+	bucket.limit, err1 = strconv.ParseUint(fields[0], 10, 64)
+	bucket.remaining, err2 = strconv.ParseUint(fields[1], 10, 64)
+	bucket.reset, err3 = strconv.ParseInt(fields[2], 10, 64)
+	bucket.known = err1 == nil && err2 == nil && err3 == nil
+	return bucket
+}
+
+// Bucket returns a snapshot of the bucket as found in the store. If the store can not be reached, the rate
+// limit of the bucket is unknown.
+func (d *DistributedRateLimit) Bucket(key string) *Bucket {
+	value, exists, err := d.store.Get(d.bucketKey(key))
+	if err != nil || !exists {
+		return &Bucket{endpoint: key}
+	}
+	return decodeBucket(key, value)
+}
+
+// BucketKey works like RateLimit.BucketKey, but the bucket hashes are shared between processes
+func (d *DistributedRateLimit) BucketKey(req *Request) string {
+	route, major := route(req)
+
+	d.mu.RLock()
+	hash, exists := d.hashes[route]
+	d.mu.RUnlock()
+
+	if !exists {
+		var err error
+		if hash, exists, err = d.store.Get(d.hashKey(route)); err != nil || !exists {
+			return req.Ratelimiter
+		}
+
+		d.mu.Lock()
+		d.hashes[route] = hash
+		d.mu.Unlock()
+	}
+	return hash + ":" + major
+}
+
+// globalTimeout returns the number of milliseconds until the global rate limit resets
+func (d *DistributedRateLimit) globalTimeout() int64 {
+	value, exists, err := d.store.Get(d.globalKey())
+	if err != nil || !exists {
+		return 0
+	}
+
+	reset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if timeout := reset - d.nowMilli(); timeout > 0 {
+		return timeout
+	}
+	return 0
+}
+
+// RateLimitTimeout returns the time left before the rate limit for a given key
+// is reset. This takes the global rate limit into account.
+func (d *DistributedRateLimit) RateLimitTimeout(key string) int64 {
+	global := d.globalTimeout()
+	unique := d.Bucket(key).timeout(d.TimeDiff.Now())
+
+	if global > unique {
+		return global
+	}
+	return unique
+}
+
+// RateLimited checks if the given key is rate limited. This takes the global
+// rate limiter into account.
+func (d *DistributedRateLimit) RateLimited(key string) bool {
+	return d.RateLimitTimeout(key) > 0
+}
+
+// WaitTime get's the remaining time before another request can be made.
+func (d *DistributedRateLimit) WaitTime(req *Request) time.Duration {
+	return time.Duration(d.RateLimitTimeout(d.BucketKey(req))) * time.Millisecond
+}
+
+// QueueDepths returns the number of requests of this process waiting in line for each bucket that has a queue
+func (d *DistributedRateLimit) QueueDepths() map[string]int {
+	return d.queues.QueueDepths()
+}
+
+// Reserve works like RateLimit.Reserve, but the slot is reserved in the store while holding the lease of the
+// bucket. If the rate limit of the bucket is unknown, the lease is held until release is called or it expires.
+func (d *DistributedRateLimit) Reserve(ctx context.Context, req *Request) (release func(), err error) {
+	for {
+		key := d.BucketKey(req)
+		queue := d.queues.Bucket(key)
+		if err = queue.enqueue(ctx); err != nil {
+			return
+		}
+
+		// the bucket of the route was discovered while waiting in line
+		if key != d.BucketKey(req) {
+			queue.next()
+			continue
+		}
+
+		var unlease func()
+		if unlease, err = d.reserve(ctx, key); err != nil {
+			queue.next()
+			return
+		}
+		if unlease == nil {
+			queue.next()
+			return func() {}, nil
+		}
+
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				unlease()
+				queue.next()
+			})
+		}, nil
+	}
+}
+
+// reserve takes a slot in the bucket. A non-nil unlease is returned when the lease is kept while the request is
+// in flight.
+func (d *DistributedRateLimit) reserve(ctx context.Context, key string) (unlease func(), err error) {
+	leaseKey := d.leaseKey(key)
+	for {
+		token := d.id + ":" + strconv.FormatUint(atomic.AddUint64(&d.leases, 1), 10)
+
+		var leased bool
+		if leased, err = d.store.SetNX(leaseKey, token, d.Lease); err != nil {
+			return
+		}
+		if !leased {
+			// another process holds the bucket
+			if err = sleep(ctx, d.PollInterval); err != nil {
+				return
+			}
+			continue
+		}
+		release := func() {
+			d.store.DeleteIfEqual(leaseKey, token)
+		}
+
+		var value string
+		if value, _, err = d.store.Get(d.bucketKey(key)); err != nil {
+			release()
+			return
+		}
+		bucket := decodeBucket(key, value)
+		wait, serialize := bucket.reserve(d.TimeDiff.Now())
+		if wait > 0 {
+			release()
+			if err = sleep(ctx, wait); err != nil {
+				return
+			}
+			continue
+		}
+
+		if serialize {
+			// hold the lease until the response reveals the rate limit
+			return release, nil
+		}
+
+		var stored bool
+		stored, err = d.store.SetIfEqual(d.bucketKey(key), value, encodeBucket(bucket), d.stateTTL(bucket.reset))
+		release()
+		if err != nil || stored {
+			return
+		}
+		// a response updated the bucket in the meantime
+	}
+}
+
+// UpdateBuckets updates the buckets in the store given the response, and moves the route of the request to the
+// bucket given by the response. See UpdateRegisters.
+func (d *DistributedRateLimit) UpdateBuckets(req *Request, resp *http.Response, content []byte) {
+	// learn which bucket the route belongs to
+	if hash := resp.Header.Get(XRateLimitBucket); hash != "" {
+		route, _ := route(req)
+		if err := d.store.Set(d.hashKey(route), hash, 24*time.Hour); err == nil {
+			d.mu.Lock()
+			d.hashes[route] = hash
+			d.mu.Unlock()
+		}
+	}
+
+	d.UpdateRegisters(d.BucketKey(req), resp, content)
+}
+
+// UpdateRegisters updates the bucket of the key in the store given the response. The update is not done under
+// the lease of the bucket, as the lease may be held by the request itself. Instead the bucket is only written if
+// no other process changed it since it was read, otherwise the update is done again on the new state. Responses
+// from the same window can only lower the remaining requests, so concurrent updates do not give back reserved
+// slots.
+func (d *DistributedRateLimit) UpdateRegisters(key string, resp *http.Response, content []byte) {
+	// update time difference
+	if discordTime, err := HeaderToTime(&resp.Header); err == nil {
+		d.TimeDiff.Update(time.Now(), discordTime)
+	}
+
+	info, err := ExtractRateLimitInfo(resp, content)
+	if err != nil {
+		return // TODO: logging
+	}
+
+	if info.Global {
+		global := &Bucket{}
+		global.update(info, d.TimeDiff.Now())
+		ttl := time.Duration(global.reset-d.nowMilli()) * time.Millisecond
+		if ttl > 0 {
+			d.store.Set(d.globalKey(), strconv.FormatInt(global.reset, 10), ttl)
+		}
+		return
+	}
+
+	if resp.Header.Get(XRateLimitRemaining) == "" && !RateLimited(resp) {
+		// the endpoint is only limited by the global rate limit
+		bucket := &Bucket{endpoint: key, known: true}
+		d.store.Set(d.bucketKey(key), encodeBucket(bucket), d.stateTTL(bucket.reset))
+		return
+	}
+
+	for {
+		value, _, err := d.store.Get(d.bucketKey(key))
+		if err != nil {
+			return // TODO: logging
+		}
+		bucket := decodeBucket(key, value)
+		bucket.update(info, d.TimeDiff.Now())
+
+		stored, err := d.store.SetIfEqual(d.bucketKey(key), value, encodeBucket(bucket), d.stateTTL(bucket.reset))
+		if err != nil || stored {
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package httd
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestDistributedRateLimits(t *testing.T) (a, b *DistributedRateLimit, closer func()) {
+	server := newFakeRedis(t)
+	storeA := NewRedisStore(server.Addr())
+	storeB := NewRedisStore(server.Addr())
+
+	a = NewDistributedRateLimit(storeA, "test:")
+	b = NewDistributedRateLimit(storeB, "test:")
+	a.PollInterval = 5 * time.Millisecond
+	b.PollInterval = 5 * time.Millisecond
+
+	return a, b, func() {
+		storeA.Close()
+		storeB.Close()
+		server.Close()
+	}
+}
+
+func TestNewClient_RateLimiter(t *testing.T) {
+	rateLimiter, _, closer := newTestDistributedRateLimits(t)
+	defer closer()
+
+	client := NewClient(&Config{
+		APIVersion:         6,
+		BotToken:           "token",
+		UserAgentSourceURL: "https://github.com/andersfylling/disgord",
+		UserAgentVersion:   "test",
+		RateLimiter:        rateLimiter,
+	})
+	if client.RateLimiter() != rateLimiter {
+		t.Error("the rate limiter given in the config was not used")
+	}
+}
+
+func TestDistributedRateLimit_sharedBucket(t *testing.T) {
+	a, b, closer := newTestDistributedRateLimits(t)
+	defer closer()
+
+	req := &Request{Method: http.MethodPost, Endpoint: "/channels/1/messages", Ratelimiter: "c:1:m"}
+	resp := &http.Response{
+		Header:     make(http.Header),
+		StatusCode: http.StatusOK,
+	}
+	resp.Header.Set(XRateLimitBucket, "abcd1234")
+	resp.Header.Set(XRateLimitLimit, "5")
+	resp.Header.Set(XRateLimitRemaining, "2")
+	resp.Header.Set(XRateLimitReset, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	a.UpdateBuckets(req, resp, nil)
+
+	if key := b.BucketKey(req); key != "abcd1234:channels/1" {
+		t.Errorf("the bucket hash was not shared, got key %s", key)
+	}
+
+	for i := 0; i < 2; i++ {
+		release, err := b.Reserve(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := a.Reserve(ctx, req); err != context.DeadlineExceeded {
+		t.Errorf("the slots reserved by another process were not respected, got %v", err)
+	}
+	if !a.RateLimited(a.BucketKey(req)) {
+		t.Error("expected the bucket to be rate limited")
+	}
+}
+
+func TestDistributedRateLimit_leaseExpires(t *testing.T) {
+	a, b, closer := newTestDistributedRateLimits(t)
+	defer closer()
+	a.Lease = 50 * time.Millisecond
+	b.Lease = 50 * time.Millisecond
+
+	req := &Request{Method: http.MethodGet, Endpoint: "/channels/1", Ratelimiter: "c:1"}
+
+	// the rate limit is unknown, so the lease is held while the request is in flight. The process then
+	// crashes and never releases it.
+	if _, err := a.Reserve(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	release, err := b.Reserve(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	if time.Since(start) < 40*time.Millisecond {
+		t.Error("the bucket was taken while another process held the lease")
+	}
+}
+
+func TestDistributedRateLimit_global(t *testing.T) {
+	a, b, closer := newTestDistributedRateLimits(t)
+	defer closer()
+
+	resp := &http.Response{
+		Header:     make(http.Header),
+		StatusCode: http.StatusTooManyRequests,
+	}
+	resp.Header.Set(XRateLimitGlobal, "true")
+	a.UpdateBuckets(&Request{Method: http.MethodGet, Endpoint: "/users/@me"}, resp, []byte(`{"retry_after": 500, "global": true}`))
+
+	if !b.RateLimited("anything") {
+		t.Error("the global rate limit was not shared")
+	}
+	if wait := b.WaitTime(&Request{Method: http.MethodGet, Endpoint: "/gateway"}); wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("unexpected wait time %s", wait)
+	}
+}
@@ -1,9 +1,11 @@
 package httd
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -139,3 +141,190 @@ func TestExtractRateLimitGlobal(t *testing.T) {
 		t.Error("was not rate limited on a global scale")
 	}
 }
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		method   string
+		endpoint string
+		route    string
+		major    string
+	}{
+		{http.MethodGet, "/channels/123/messages?limit=50", "GET /channels/{major}/messages", "channels/123"},
+		{http.MethodDelete, "/channels/123/messages/456", "DELETE /channels/{major}/messages/{id}", "channels/123"},
+		{http.MethodPut, "/channels/123/messages/456/reactions/%F0%9F%91%8D/@me", "PUT /channels/{major}/messages/{id}/reactions/{emoji}/@me", "channels/123"},
+		{http.MethodPost, "/webhooks/123/token?wait=true", "POST /webhooks/{major}/{token}", "webhooks/123/token"},
+		{http.MethodGet, "/users/@me/guilds", "GET /users/@me/guilds", ""},
+		{http.MethodGet, "/users/123", "GET /users/{id}", ""},
+		{http.MethodGet, "/invites/abc", "GET /invites/{code}", ""},
+	}
+
+	for _, test := range tests {
+		route, major := route(&Request{Method: test.method, Endpoint: test.endpoint})
+		if route != test.route || major != test.major {
+			t.Errorf("%s %s: expected (%s, %s), got (%s, %s)", test.method, test.endpoint, test.route, test.major, route, major)
+		}
+	}
+}
+
+func TestRateLimit_BucketKey(t *testing.T) {
+	rl := NewRateLimit()
+	messages := &Request{Method: http.MethodGet, Endpoint: "/channels/1/messages", Ratelimiter: "c:1:m"}
+	message := &Request{Method: http.MethodGet, Endpoint: "/channels/1/messages/2", Ratelimiter: "c:1:m:2"}
+	otherChannel := &Request{Method: http.MethodGet, Endpoint: "/channels/3/messages/4", Ratelimiter: "c:3:m:4"}
+
+	if key := rl.BucketKey(messages); key != messages.Ratelimiter {
+		t.Errorf("expected the fallback key %s before the first response, got %s", messages.Ratelimiter, key)
+	}
+
+	respond := func(req *Request, remaining int) {
+		resp := &http.Response{
+			Header:     make(http.Header),
+			StatusCode: http.StatusOK,
+		}
+		resp.Header.Set(XRateLimitBucket, "abcd1234")
+		resp.Header.Set(XRateLimitLimit, "5")
+		resp.Header.Set(XRateLimitRemaining, strconv.Itoa(remaining))
+		resp.Header.Set(XRateLimitReset, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		rl.UpdateBuckets(req, resp, nil)
+	}
+	respond(messages, 1)
+	respond(message, 0)
+
+	if rl.BucketKey(messages) != "abcd1234:channels/1" || rl.BucketKey(message) != rl.BucketKey(messages) {
+		t.Errorf("routes in the same discord bucket must share a key, got %s and %s", rl.BucketKey(messages), rl.BucketKey(message))
+	}
+	if !rl.RateLimited(rl.BucketKey(messages)) {
+		t.Error("routes sharing a bucket must share the rate limit")
+	}
+
+	respond(otherChannel, 5)
+	if rl.BucketKey(otherChannel) == rl.BucketKey(message) {
+		t.Error("requests with different major parameters must not share a bucket")
+	}
+	if rl.RateLimited(rl.BucketKey(otherChannel)) {
+		t.Error("a different major parameter must not be rate limited")
+	}
+}
+
+func TestRateLimit_UpdateRegistersGlobalOnly(t *testing.T) {
+	rl := NewRateLimit()
+	resp := &http.Response{
+		Header:     make(http.Header),
+		StatusCode: http.StatusOK,
+	}
+	req := &Request{Method: http.MethodGet, Endpoint: "/gateway", Ratelimiter: "/gateway"}
+	rl.UpdateBuckets(req, resp, nil)
+
+	if wait, serialize := rl.Bucket(req.Ratelimiter).reserve(time.Now()); wait > 0 || serialize {
+		t.Error("an endpoint without a per route rate limit must not be limited")
+	}
+}
+
+func TestBucket_reserve(t *testing.T) {
+	now := time.Now()
+	bucket := &Bucket{}
+
+	if _, serialize := bucket.reserve(now); !serialize {
+		t.Error("requests must be serialized while the rate limit is unknown")
+	}
+
+	bucket.update(&RateLimitInfo{
+		Limit:     2,
+		Remaining: 2,
+		Reset:     now.Add(time.Second).UnixNano() / int64(time.Millisecond),
+	}, now)
+	for i := 0; i < 2; i++ {
+		if wait, serialize := bucket.reserve(now); wait > 0 || serialize {
+			t.Fatalf("reservation %d should not wait", i)
+		}
+	}
+	if wait, _ := bucket.reserve(now); wait <= 0 || wait > time.Second {
+		t.Errorf("expected to wait until the bucket resets, got %s", wait)
+	}
+
+	// a late response from the same window must not give back reserved slots
+	bucket.update(&RateLimitInfo{Limit: 2, Remaining: 1, Reset: bucket.reset}, now)
+	if bucket.remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d", bucket.remaining)
+	}
+
+	if wait, _ := bucket.reserve(now.Add(2 * time.Second)); wait > 0 {
+		t.Error("the bucket should have been reset")
+	}
+	if bucket.remaining != 1 {
+		t.Errorf("expected 1 remaining after the reset, got %d", bucket.remaining)
+	}
+}
+
+func TestRateLimit_Reserve(t *testing.T) {
+	rl := NewRateLimit()
+	req := &Request{Method: http.MethodGet, Endpoint: "/channels/1/messages", Ratelimiter: "c:1:m"}
+
+	first, err := rl.Reserve(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForDepth := func(depth int) {
+		deadline := time.Now().Add(time.Second)
+		for rl.QueueDepths()[req.Ratelimiter] != depth {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected a queue depth of %d, got %d", depth, rl.QueueDepths()[req.Ratelimiter])
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := rl.Reserve(ctx, req)
+			errs <- err
+		}()
+		waitForDepth(1)
+		cancel()
+
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+		waitForDepth(0)
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				release, err := rl.Reserve(context.Background(), req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				release()
+			}(i)
+			waitForDepth(i + 1)
+		}
+
+		// the rate limit is unknown, so nobody gets through before the first request is done
+		mu.Lock()
+		if len(order) != 0 {
+			t.Error("requests were sent before the rate limit was known")
+		}
+		mu.Unlock()
+
+		first()
+		wg.Wait()
+		for i := range order {
+			if order[i] != i {
+				t.Fatalf("requests were not handled in order: %v", order)
+			}
+		}
+	})
+}
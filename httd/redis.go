@@ -0,0 +1,240 @@
+package httd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// deleteIfEqualScript deletes a key only if it holds the given value, such that a lease is only released by its
+// owner
+const deleteIfEqualScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+
+// setIfEqualScript sets a key only if it holds the given value, or does not exist when the value is empty. The
+// expiry is given in milliseconds, zero means the key never expires.
+const setIfEqualScript = `if (redis.call("get", KEYS[1]) or "") ~= ARGV[1] then return 0 end
+if ARGV[3] == "0" then redis.call("set", KEYS[1], ARGV[2]) else redis.call("set", KEYS[1], ARGV[2], "px", ARGV[3]) end
+return 1`
+
+// NewRedisStore creates a RateLimitStore which talks to a server using the Redis protocol (RESP) at the given
+// address, eg. "localhost:6379". The connection is established on the first command.
+func NewRedisStore(addr string) *RedisStore {
+	return &RedisStore{
+		addr:    addr,
+		Timeout: 2 * time.Second,
+	}
+}
+
+// RedisStore is a minimal Redis client which implements RateLimitStore. Commands are sent one at a time over a
+// single connection, which is re-established if it fails.
+type RedisStore struct {
+	sync.Mutex
+	addr string
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// Timeout is the deadline for each command, including dialing
+	Timeout time.Duration
+
+	// Password is sent with the AUTH command when connecting, if set
+	Password string
+}
+
+var _ RateLimitStore = (*RedisStore)(nil)
+
+// errRedisNil is returned when the server replies with a nil value
+var errRedisNil = errors.New("redis: nil")
+
+func (r *RedisStore) connect() (err error) {
+	if r.conn, err = net.DialTimeout("tcp", r.addr, r.Timeout); err != nil {
+		return
+	}
+	r.rw = bufio.NewReadWriter(bufio.NewReader(r.conn), bufio.NewWriter(r.conn))
+
+	if r.Password != "" {
+		if _, err = r.send("AUTH", r.Password); err != nil {
+			r.close()
+		}
+	}
+	return
+}
+
+func (r *RedisStore) close() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// do sends a command and returns the reply
+func (r *RedisStore) do(args ...string) (reply interface{}, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conn == nil {
+		if err = r.connect(); err != nil {
+			return
+		}
+	}
+
+	reply, err = r.send(args...)
+	if err != nil && err != errRedisNil {
+		if _, isServerErr := err.(redisError); !isServerErr {
+			// the connection is in an unknown state
+			r.close()
+		}
+	}
+	return
+}
+
+func (r *RedisStore) send(args ...string) (reply interface{}, err error) {
+	if err = r.conn.SetDeadline(time.Now().Add(r.Timeout)); err != nil {
+		return
+	}
+
+	r.rw.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		r.rw.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if err = r.rw.Flush(); err != nil {
+		return
+	}
+
+	return readRedisReply(r.rw.Reader)
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRedisReply parses a single RESP reply
+func readRedisReply(r *bufio.Reader) (reply interface{}, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, content := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return content, nil
+	case '-':
+		return nil, redisError(content)
+	case ':':
+		return strconv.ParseInt(content, 10, 64)
+	case '$':
+		var size int
+		if size, err = strconv.Atoi(content); err != nil {
+			return
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return
+		}
+		return string(buf[:size]), nil
+	case '*':
+		var size int
+		if size, err = strconv.Atoi(content); err != nil {
+			return
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		replies := make([]interface{}, size)
+		for i := range replies {
+			if replies[i], err = readRedisReply(r); err != nil && err != errRedisNil {
+				return
+			}
+		}
+		return replies, nil
+	default:
+		return nil, errors.New("redis: unknown reply type " + string(kind))
+	}
+}
+
+// withTTL adds the expiry option to a SET command. Redis does not accept an expiry below one millisecond.
+func withTTL(args []string, ttl time.Duration) []string {
+	if ttl <= 0 {
+		return args
+	}
+
+	milliseconds := int64(ttl / time.Millisecond)
+	if milliseconds < 1 {
+		milliseconds = 1
+	}
+	return append(args, "PX", strconv.FormatInt(milliseconds, 10))
+}
+
+// Get implements RateLimitStore
+func (r *RedisStore) Get(key string) (value string, exists bool, err error) {
+	reply, err := r.do("GET", key)
+	if err == errRedisNil {
+		return "", false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	value, exists = reply.(string)
+	return
+}
+
+// Set implements RateLimitStore
+func (r *RedisStore) Set(key, value string, ttl time.Duration) (err error) {
+	_, err = r.do(withTTL([]string{"SET", key, value}, ttl)...)
+	return
+}
+
+// SetNX implements RateLimitStore
+func (r *RedisStore) SetNX(key, value string, ttl time.Duration) (ok bool, err error) {
+	_, err = r.do(withTTL([]string{"SET", key, value, "NX"}, ttl)...)
+	if err == errRedisNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetIfEqual implements RateLimitStore
+func (r *RedisStore) SetIfEqual(key, old, value string, ttl time.Duration) (ok bool, err error) {
+	var milliseconds int64
+	if ttl > 0 {
+		if milliseconds = int64(ttl / time.Millisecond); milliseconds < 1 {
+			milliseconds = 1
+		}
+	}
+
+	reply, err := r.do("EVAL", setIfEqualScript, "1", key, old, value, strconv.FormatInt(milliseconds, 10))
+	if err != nil {
+		return
+	}
+	stored, _ := reply.(int64)
+	return stored == 1, nil
+}
+
+// DeleteIfEqual implements RateLimitStore
+func (r *RedisStore) DeleteIfEqual(key, value string) (err error) {
+	_, err = r.do("EVAL", deleteIfEqualScript, "1", key, value)
+	return
+}
+
+// Close closes the connection to the server
+func (r *RedisStore) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.close()
+	return nil
+}
@@ -0,0 +1,211 @@
+package httd
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process server implementing the subset of the Redis protocol used by RedisStore
+type fakeRedis struct {
+	sync.Mutex
+	listener net.Listener
+	values   map[string]string
+	expiry   map[string]time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fakeRedis{
+		listener: listener,
+		values:   make(map[string]string),
+		expiry:   make(map[string]time.Time),
+	}
+	go r.serve()
+	return r
+}
+
+func (r *fakeRedis) Addr() string {
+	return r.listener.Addr().String()
+}
+
+func (r *fakeRedis) Close() {
+	r.listener.Close()
+}
+
+func (r *fakeRedis) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		go r.handle(conn)
+	}
+}
+
+func (r *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+		args := make([]string, 0)
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		if _, err = io.WriteString(conn, r.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+// get must be called with the lock held
+func (r *fakeRedis) get(key string) (string, bool) {
+	if expiry, ok := r.expiry[key]; ok && time.Now().After(expiry) {
+		delete(r.values, key)
+		delete(r.expiry, key)
+	}
+	value, ok := r.values[key]
+	return value, ok
+}
+
+func (r *fakeRedis) exec(args []string) string {
+	r.Lock()
+	defer r.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		value, ok := r.get(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	case "SET":
+		key, value := args[1], args[2]
+		var nx bool
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, err := strconv.Atoi(args[i])
+				if err != nil || ms <= 0 {
+					return "-ERR invalid expire time in set\r\n"
+				}
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if _, exists := r.get(key); nx && exists {
+			return "$-1\r\n"
+		}
+		r.values[key] = value
+		delete(r.expiry, key)
+		if ttl > 0 {
+			r.expiry[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "EVAL":
+		switch args[1] {
+		case deleteIfEqualScript:
+			if value, ok := r.get(args[3]); ok && value == args[4] {
+				delete(r.values, args[3])
+				delete(r.expiry, args[3])
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		case setIfEqualScript:
+			if value, _ := r.get(args[3]); value != args[4] {
+				return ":0\r\n"
+			}
+			r.values[args[3]] = args[5]
+			delete(r.expiry, args[3])
+			if ms, _ := strconv.Atoi(args[6]); ms > 0 {
+				r.expiry[args[3]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			return ":1\r\n"
+		default:
+			return "-ERR unknown script\r\n"
+		}
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.Close()
+	store := NewRedisStore(server.Addr())
+	defer store.Close()
+
+	if _, exists, err := store.Get("missing"); err != nil || exists {
+		t.Errorf("expected a missing key, got exists=%t err=%v", exists, err)
+	}
+
+	if err := store.Set("key", "value with spaces\r\n", 0); err != nil {
+		t.Fatal(err)
+	}
+	if value, exists, err := store.Get("key"); err != nil || !exists || value != "value with spaces\r\n" {
+		t.Errorf("unexpected value %q, exists=%t, err=%v", value, exists, err)
+	}
+
+	if ok, err := store.SetNX("lease", "a", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("expected to get the lease, got ok=%t err=%v", ok, err)
+	}
+	if ok, err := store.SetNX("lease", "b", time.Second); err != nil || ok {
+		t.Errorf("the lease was taken twice, got ok=%t err=%v", ok, err)
+	}
+
+	if err := store.DeleteIfEqual("lease", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := store.Get("lease"); !exists {
+		t.Error("the lease was released by a process that did not own it")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if ok, err := store.SetNX("lease", "b", time.Second); err != nil || !ok {
+		t.Errorf("the lease did not expire, got ok=%t err=%v", ok, err)
+	}
+	if err := store.DeleteIfEqual("lease", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := store.Get("lease"); exists {
+		t.Error("the lease was not released by its owner")
+	}
+
+	if ok, err := store.SetIfEqual("swap", "", "a", time.Second); err != nil || !ok {
+		t.Errorf("expected a missing key to be set, got ok=%t err=%v", ok, err)
+	}
+	if ok, err := store.SetIfEqual("swap", "b", "c", time.Second); err != nil || ok {
+		t.Errorf("the key was set although it changed, got ok=%t err=%v", ok, err)
+	}
+	if ok, err := store.SetIfEqual("swap", "a", "c", 0); err != nil || !ok {
+		t.Errorf("expected the key to be set, got ok=%t err=%v", ok, err)
+	}
+	if value, _, _ := store.Get("swap"); value != "c" {
+		t.Errorf("expected the new value, got %q", value)
+	}
+
+	t.Run("server error", func(t *testing.T) {
+		if _, err := store.do("UNKNOWN"); err == nil {
+			t.Error("expected the error reply to be returned")
+		}
+		if _, _, err := store.Get("key"); err != nil {
+			t.Errorf("the connection should be usable after an error reply, got %v", err)
+		}
+	})
+}
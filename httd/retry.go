@@ -0,0 +1,112 @@
+package httd
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryPolicy is used when no retry policy is specified in the Config
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+}
+
+// RetryPolicy decides when a failed request is sent again. Requests that were rate limited (429) are retried
+// once the rate limit given by Discord resets, regardless of the http method, as Discord did not process them.
+// Like WaitIfRateLimited, the rate limit error is returned instead if the client cancels requests when rate
+// limited, or if the wait is not shorter than the http.Client.Timeout.
+// Requests that failed with a server error (5xx) are retried using exponential backoff, but only for idempotent
+// methods unless RetryNonIdempotent is set, as Discord might have processed the request before failing.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is retried after the first attempt. Zero disables retries.
+	MaxRetries uint
+
+	// MinBackoff is the wait before the first retry of a server error. It is doubled for every retry.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries of a server error
+	MaxBackoff time.Duration
+
+	// RetryNonIdempotent allows POST and PATCH requests to be retried on server errors. This may cause
+	// duplicates, such as a message being posted twice.
+	RetryNonIdempotent bool
+}
+
+// idempotent checks if sending the same request multiple times has the same effect as sending it once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// transientServerError checks if the http status code indicates a temporary problem on Discord's side
+func transientServerError(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the wait before the given retry of a server error
+func (p *RetryPolicy) backoff(retry uint) time.Duration {
+	wait := p.MinBackoff
+	for i := uint(0); i < retry && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
+// retryAfter decides if a request should be retried given the response of the last attempt, and how long to
+// wait before doing so. retry is the number of retries done so far.
+func (p *RetryPolicy) retryAfter(method string, resp *http.Response, body []byte, retry uint) (wait time.Duration, ok bool) {
+	if resp == nil || retry >= p.MaxRetries {
+		return 0, false
+	}
+
+	if RateLimited(resp) {
+		info, err := ExtractRateLimitInfo(resp, body)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(info.RetryAfter) * time.Millisecond, true
+	}
+
+	if transientServerError(resp.StatusCode) && (p.RetryNonIdempotent || idempotent(method)) {
+		return p.backoff(retry), true
+	}
+
+	return 0, false
+}
+
+// waitForRateLimit checks if the client may wait for a rate limit to reset before retrying a request, by the same
+// rules as WaitIfRateLimited
+func (c *Client) waitForRateLimit(wait time.Duration) bool {
+	return !c.cancelRequestWhenRateLimited && wait < c.httpClient.Timeout
+}
+
+// sleep waits for the given duration, or until the context is done
+func sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
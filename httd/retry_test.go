@@ -0,0 +1,194 @@
+package httd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for retry, wait := range expected {
+		if got := policy.backoff(uint(retry)); got != wait*time.Millisecond {
+			t.Errorf("retry %d: expected %s, got %s", retry, wait*time.Millisecond, got)
+		}
+	}
+}
+
+// testRetryServer responds with the given status codes in order, and 200 OK once they are used up
+type testRetryServer struct {
+	sync.Mutex
+	*httptest.Server
+	codes  []int
+	header http.Header
+	bodies []string
+}
+
+func newTestRetryServer(header http.Header, codes ...int) *testRetryServer {
+	s := &testRetryServer{codes: codes, header: header}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.Lock()
+		s.bodies = append(s.bodies, string(body))
+		code := http.StatusOK
+		if len(s.codes) > 0 {
+			code = s.codes[0]
+			s.codes = s.codes[1:]
+		}
+		s.Unlock()
+
+		for key, values := range s.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(code)
+		if code == http.StatusTooManyRequests {
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 20, "global": false}`))
+		}
+	}))
+	return s
+}
+
+func (s *testRetryServer) attempts() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.bodies)
+}
+
+func TestRequestRetry(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	t.Run("rate limited post", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusTooManyRequests)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+
+		start := time.Now()
+		_, _, err := client.Post(&Request{Endpoint: "/", Ratelimiter: "b", Body: "hello", ContentType: ContentTypeJSON})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if srv.attempts() != 2 {
+			t.Errorf("expected 2 attempts, got %d", srv.attempts())
+		}
+		if time.Since(start) < 20*time.Millisecond {
+			t.Error("retry_after was not respected")
+		}
+		if srv.bodies[0] != srv.bodies[1] || srv.bodies[1] != `"hello"` {
+			t.Errorf("the body was not sent again on retry: %v", srv.bodies)
+		}
+	})
+
+	t.Run("global rate limit", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(XRateLimitGlobal, "true")
+		srv := newTestRetryServer(header, http.StatusTooManyRequests)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+
+		if _, _, err := client.Get(&Request{Endpoint: "/", Ratelimiter: "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if srv.attempts() != 2 {
+			t.Errorf("expected 2 attempts, got %d", srv.attempts())
+		}
+	})
+
+	t.Run("cancel when rate limited", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusTooManyRequests)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+		client.cancelRequestWhenRateLimited = true
+
+		_, _, err := client.Get(&Request{Endpoint: "/", Ratelimiter: "b"})
+		if e, ok := err.(*ErrorREST); !ok || e.HTTPCode != http.StatusTooManyRequests {
+			t.Errorf("expected the rate limit error to be returned, got %v", err)
+		}
+		if srv.attempts() != 1 {
+			t.Errorf("rate limited requests must not be retried when cancelled, got %d attempts", srv.attempts())
+		}
+	})
+
+	t.Run("rate limit beyond http timeout", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusTooManyRequests)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+		client.httpClient.Timeout = 20 * time.Millisecond
+
+		_, _, err := client.Get(&Request{Endpoint: "/", Ratelimiter: "b"})
+		if e, ok := err.(*ErrorREST); !ok || e.HTTPCode != http.StatusTooManyRequests {
+			t.Errorf("expected the rate limit error to be returned, got %v", err)
+		}
+		if srv.attempts() != 1 {
+			t.Errorf("the retry must not wait beyond the http timeout, got %d attempts", srv.attempts())
+		}
+	})
+
+	t.Run("server error get", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusBadGateway)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+
+		_, _, err := client.Get(&Request{Endpoint: "/", Ratelimiter: "b"})
+		if e, ok := err.(*ErrorREST); !ok || e.HTTPCode != http.StatusBadGateway {
+			t.Errorf("expected the last error to be returned, got %v", err)
+		}
+		if srv.attempts() != 3 {
+			t.Errorf("expected 3 attempts, got %d", srv.attempts())
+		}
+	})
+
+	t.Run("server error post", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusBadGateway)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+
+		if _, _, err := client.Post(&Request{Endpoint: "/", Ratelimiter: "b"}); err == nil {
+			t.Error("expected an error")
+		}
+		if srv.attempts() != 1 {
+			t.Errorf("non idempotent requests must not be retried on server errors, got %d attempts", srv.attempts())
+		}
+	})
+
+	t.Run("request override", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusBadGateway)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+
+		override := policy
+		override.RetryNonIdempotent = true
+		if _, _, err := client.Post(&Request{Endpoint: "/", Ratelimiter: "b", RetryPolicy: &override}); err != nil {
+			t.Fatal(err)
+		}
+		if srv.attempts() != 2 {
+			t.Errorf("expected 2 attempts, got %d", srv.attempts())
+		}
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		srv := newTestRetryServer(nil, http.StatusNotFound)
+		defer srv.Close()
+		client := newTestClient(srv.URL)
+		client.retryPolicy = policy
+
+		if _, _, err := client.Get(&Request{Endpoint: "/", Ratelimiter: "b"}); !IsNotFound(err) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if srv.attempts() != 1 {
+			t.Errorf("client errors must not be retried, got %d attempts", srv.attempts())
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package disgord
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andersfylling/disgord/event"
+)
+
+// offlineMembersTimeout is the time a large guild may take to send its members. See
+// CacheConfig.LoadOfflineMembers.
+const offlineMembersTimeout = 2 * time.Minute
+
+// guildMembersRequest collects the GuildMembersChunk events of a single request
+type guildMembersRequest struct {
+	chunks chan *GuildMembersChunk
+	done   chan struct{}
+}
+
+// wait collects the members from every chunk, until the last chunk is received or the context is done
+func (r *guildMembersRequest) wait(ctx context.Context) (members []*Member, err error) {
+	var received uint
+	for {
+		select {
+		case chunk := <-r.chunks:
+			members = append(members, chunk.Members...)
+			received++
+			if received >= chunk.ChunkCount {
+				return members, nil
+			}
+		case <-ctx.Done():
+			return members, ctx.Err()
+		}
+	}
+}
+
+// guildMembersRequests pairs the GuildMembersChunk events with the request they belong to, using the nonce
+type guildMembersRequests struct {
+	sync.Mutex
+	requests map[string]*guildMembersRequest
+	nonce    uint64
+}
+
+// add registers a new request, and returns the nonce identifying its chunks
+func (r *guildMembersRequests) add() (nonce string, request *guildMembersRequest) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.requests == nil {
+		r.requests = make(map[string]*guildMembersRequest)
+	}
+	r.nonce++
+	nonce = strconv.FormatUint(r.nonce, 36)
+	request = &guildMembersRequest{
+		chunks: make(chan *GuildMembersChunk),
+		done:   make(chan struct{}),
+	}
+	r.requests[nonce] = request
+	return
+}
+
+// remove stops delivering chunks to the request
+func (r *guildMembersRequests) remove(nonce string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if request, exists := r.requests[nonce]; exists {
+		close(request.done)
+		delete(r.requests, nonce)
+	}
+}
+
+// deliver passes the chunk on to the request it belongs to, if it is still waiting
+func (r *guildMembersRequests) deliver(chunk *GuildMembersChunk) {
+	if chunk.Nonce == "" {
+		return
+	}
+
+	r.Lock()
+	request, exists := r.requests[chunk.Nonce]
+	r.Unlock()
+	if !exists {
+		return
+	}
+
+	select {
+	case request.chunks <- chunk:
+	case <-request.done:
+	}
+}
+
+// RequestGuildMembers requests the members of a guild over the gateway, and waits for every GuildMembersChunk
+// event of the request. The members are cached as the chunks are received. Use the context to decide how long
+// to wait; the members received so far are returned with the context error. The Nonce of the command is
+// replaced.
+func (c *Client) RequestGuildMembers(ctx context.Context, cmd *RequestGuildMembersCommand) (members []*Member, err error) {
+	if cmd == nil || cmd.GuildID.Empty() {
+		return nil, newErrorMissingSnowflake("the guild id is missing")
+	}
+
+	nonce, request := c.memberRequests.add()
+	defer c.memberRequests.remove(nonce)
+
+	// the command is not changed, as the caller might reuse it
+	withNonce := *cmd
+	withNonce.Nonce = nonce
+
+	c.shardMngr.RegisterEvent(event.GuildMembersChunk)
+	if err = c.shardMngr.Emit(CommandRequestGuildMembers, &withNonce); err != nil {
+		return
+	}
+
+	return request.wait(ctx)
+}
+
+// offlineMembersQueue holds the large guilds of a shard whose offline members are not loaded yet
+type offlineMembersQueue struct {
+	guilds  []Snowflake
+	running bool
+}
+
+// offlineMembersLoader loads the offline members of large guilds, one guild at a time per shard
+type offlineMembersLoader struct {
+	sync.Mutex
+	queues map[uint]*offlineMembersQueue
+}
+
+// queue adds the guild to the queue of its shard. It returns true if the queue must be started.
+func (l *offlineMembersLoader) queue(shardID uint, guildID Snowflake) (start bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.queues == nil {
+		l.queues = make(map[uint]*offlineMembersQueue)
+	}
+	q, exists := l.queues[shardID]
+	if !exists {
+		q = &offlineMembersQueue{}
+		l.queues[shardID] = q
+	}
+	for _, id := range q.guilds {
+		if id == guildID {
+			return false
+		}
+	}
+
+	q.guilds = append(q.guilds, guildID)
+	start = !q.running
+	q.running = true
+	return
+}
+
+// next removes the next guild from the queue of the shard. ok is false once the queue is empty.
+func (l *offlineMembersLoader) next(shardID uint) (guildID Snowflake, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	q := l.queues[shardID]
+	if len(q.guilds) == 0 {
+		q.running = false
+		return
+	}
+	guildID, q.guilds = q.guilds[0], q.guilds[1:]
+	return guildID, true
+}
+
+// loadOfflineMembers requests the offline members of a large guild, see CacheConfig.LoadOfflineMembers
+func (c *Client) loadOfflineMembers(guild *Guild) {
+	if !guild.Large || guild.Unavailable {
+		return
+	}
+
+	total := c.shardMngr.TotalShards()
+	if total == 0 {
+		total = 1
+	}
+	shardID := GetShardForGuildID(guild.ID, total)
+	if c.offlineMembers.queue(shardID, guild.ID) {
+		go c.loadOfflineMembersOfShard(shardID)
+	}
+}
+
+func (c *Client) loadOfflineMembersOfShard(shardID uint) {
+	for {
+		guildID, ok := c.offlineMembers.next(shardID)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), offlineMembersTimeout)
+		members, err := c.RequestGuildMembers(ctx, &RequestGuildMembersCommand{GuildID: guildID})
+		cancel()
+
+		c.membersLoaded <- &GuildMembersLoaded{
+			GuildID:     guildID,
+			MemberCount: len(members),
+			Err:         err,
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package disgord
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/disgord/websocket"
+)
+
+func TestRequestGuildMembersCommand_MarshalJSON(t *testing.T) {
+	t.Run("query", func(t *testing.T) {
+		data, err := httd.Marshal(&RequestGuildMembersCommand{GuildID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"query":""`) {
+			t.Errorf("expected an empty query to request every member, got %s", string(data))
+		}
+	})
+
+	t.Run("user ids", func(t *testing.T) {
+		data, err := httd.Marshal(&RequestGuildMembersCommand{GuildID: 1, UserIDs: []Snowflake{2, 3}, Nonce: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), `"query"`) {
+			t.Errorf("expected the query to be left out, got %s", string(data))
+		}
+		if !strings.Contains(string(data), `"user_ids"`) || !strings.Contains(string(data), `"nonce":"a"`) {
+			t.Errorf("expected the user ids and the nonce, got %s", string(data))
+		}
+	})
+}
+
+func TestGuildMembersRequests(t *testing.T) {
+	requests := &guildMembersRequests{}
+
+	t.Run("chunks", func(t *testing.T) {
+		nonce, request := requests.add()
+		defer requests.remove(nonce)
+		_, other := requests.add()
+
+		go func() {
+			requests.deliver(&GuildMembersChunk{Nonce: nonce, ChunkCount: 2, Members: []*Member{{}, {}}})
+			requests.deliver(&GuildMembersChunk{Nonce: "unknown", ChunkCount: 2, Members: []*Member{{}}})
+			requests.deliver(&GuildMembersChunk{Nonce: nonce, ChunkIndex: 1, ChunkCount: 2, Members: []*Member{{}}})
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		members, err := request.wait(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 3 {
+			t.Errorf("expected 3 members, got %d", len(members))
+		}
+
+		select {
+		case <-other.chunks:
+			t.Error("the chunks were delivered to the wrong request")
+		default:
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		nonce, request := requests.add()
+		go requests.deliver(&GuildMembersChunk{Nonce: nonce, ChunkCount: 2, Members: []*Member{{}}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		members, err := request.wait(ctx)
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the request to time out, got %v", err)
+		}
+		if len(members) != 1 {
+			t.Errorf("expected the members received so far, got %d", len(members))
+		}
+
+		// chunks arriving after the request gave up are dropped
+		requests.remove(nonce)
+		requests.deliver(&GuildMembersChunk{Nonce: nonce, ChunkIndex: 1, ChunkCount: 2})
+	})
+}
+
+func TestOfflineMembersLoader(t *testing.T) {
+	loader := &offlineMembersLoader{}
+
+	if !loader.queue(0, 1) {
+		t.Error("expected the queue of shard 0 to be started")
+	}
+	if loader.queue(0, 2) || loader.queue(0, 1) {
+		t.Error("the queue of shard 0 is already running")
+	}
+	if !loader.queue(1, 3) {
+		t.Error("expected every shard to have its own queue")
+	}
+
+	for _, expected := range []Snowflake{1, 2} {
+		if guildID, ok := loader.next(0); !ok || guildID != expected {
+			t.Errorf("expected guild %d to be loaded next, got %d", expected, guildID)
+		}
+	}
+	if _, ok := loader.next(0); ok {
+		t.Error("expected the queue of shard 0 to be empty")
+	}
+	if !loader.queue(0, 4) {
+		t.Error("expected the stopped queue to be started again")
+	}
+}
+
+func TestClient_eventHandlerMembersLoaded(t *testing.T) {
+	mngr := newTestShardManager(&ShardConfig{})
+	socketEvtChan := make(chan *websocket.Event)
+	c := &Client{
+		shardMngr:     mngr,
+		socketEvtChan: socketEvtChan,
+		evtDispatch:   NewDispatch(mngr),
+		membersLoaded: make(chan *GuildMembersLoaded),
+	}
+	c.evtDispatch.start()
+	defer c.evtDispatch.stop()
+	go c.eventHandler()
+	defer close(socketEvtChan)
+
+	go func() {
+		// wait for the waiter to be added
+		for !mngr.tracksEvent(EventGuildMembersLoaded) {
+			time.Sleep(time.Millisecond)
+		}
+		c.membersLoaded <- &GuildMembersLoaded{GuildID: 1, MemberCount: 2}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	box, err := c.WaitFor(ctx, EventGuildMembersLoaded, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded := box.(*GuildMembersLoaded); loaded.GuildID != 1 || loaded.Ctx == nil {
+		t.Errorf("expected the event to be dispatched by the event handler, got %+v", loaded)
+	}
+}
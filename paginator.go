@@ -0,0 +1,223 @@
+package disgord
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reactions used to flip the pages of a Paginator
+const (
+	PaginatorPrevious = "\u2b05\ufe0f" // left arrow
+	PaginatorNext     = "\u27a1\ufe0f" // right arrow
+)
+
+// paginatorTimeout is the default PaginatorConfig.Timeout
+const paginatorTimeout = 2 * time.Minute
+
+// PaginatorConfig decides who can flip the pages of a Paginator, and for how long
+type PaginatorConfig struct {
+	// UserID is the only user allowed to flip the pages. Anyone but the bot may flip them if empty.
+	UserID Snowflake
+
+	// Timeout is how long the pages can be flipped. Defaults to 2 minutes.
+	Timeout time.Duration
+}
+
+// Paginator is a message showing one embed out of several pages. Users flip the pages by pressing the
+// PaginatorPrevious and PaginatorNext reactions. See Client.SendPaginatedEmbed.
+type Paginator struct {
+	Message *Message
+
+	pages []*ChannelEmbed
+
+	mu   sync.Mutex
+	page int
+
+	// removals are the reactions of users that flipped the page, which are yet to be removed
+	removals []*MessageReactionAdd
+	flipped  chan struct{}
+
+	collector *ReactionCollector
+	done      chan struct{}
+}
+
+// Page returns the index of the page currently shown
+func (p *Paginator) Page() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.page
+}
+
+// Stop ends the pagination, the message keeps showing the current page
+func (p *Paginator) Stop() {
+	if p.collector != nil {
+		p.collector.Stop()
+	}
+}
+
+// Wait blocks until the pages can no longer be flipped, and the arrow reactions of the bot are removed
+func (p *Paginator) Wait() {
+	<-p.done
+}
+
+// flip moves to the previous or next page, wrapping around at either end, and returns the new page index
+func (p *Paginator) flip(next bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if next {
+		p.page = (p.page + 1) % len(p.pages)
+	} else {
+		p.page = (p.page - 1 + len(p.pages)) % len(p.pages)
+	}
+	return p.page
+}
+
+// handle flips the page for the reaction. The message is edited by update, as the collector must not be held up
+// by the REST calls.
+func (p *Paginator) handle(reaction *MessageReactionAdd) {
+	p.flip(sameEmoji(reaction.PartialEmoji.Name, PaginatorNext))
+
+	p.mu.Lock()
+	p.removals = append(p.removals, reaction)
+	p.mu.Unlock()
+
+	select {
+	case p.flipped <- struct{}{}:
+	default:
+		// the message is yet to be updated, which shows the latest page
+	}
+}
+
+// update shows the current page, and removes the reactions of the users, until the collector is done. Several
+// flips are shown by a single edit.
+func (p *Paginator) update(c *Client) {
+	for {
+		select {
+		case <-p.flipped:
+			p.show(c)
+		case <-p.collector.Done():
+			// the handler is done, but may have flipped the page right before the collection ended
+			select {
+			case <-p.flipped:
+				p.show(c)
+			default:
+			}
+			return
+		}
+	}
+}
+
+// show edits the message to show the current page, and removes the reactions that flipped the page
+func (p *Paginator) show(c *Client) {
+	channelID, msgID := p.Message.ChannelID, p.Message.ID
+
+	p.mu.Lock()
+	page, removals := p.page, p.removals
+	p.removals = nil
+	p.mu.Unlock()
+
+	// errors are ignored, as the user can simply press the arrow again
+	c.EditMessage(channelID, msgID, &EditMessageParams{
+		Embed: paginatorPage(p.pages, page),
+	})
+	for _, reaction := range removals {
+		emoji := PaginatorPrevious
+		if sameEmoji(reaction.PartialEmoji.Name, PaginatorNext) {
+			emoji = PaginatorNext
+		}
+		c.DeleteUserReaction(channelID, msgID, reaction.UserID, emoji)
+	}
+}
+
+// paginatorPage returns a copy of the page, with the page number as the footer unless the page has a footer
+func paginatorPage(pages []*ChannelEmbed, i int) *ChannelEmbed {
+	page := pages[i].DeepCopy().(*ChannelEmbed)
+	if page.Footer == nil && len(pages) > 1 {
+		page.Footer = &ChannelEmbedFooter{
+			Text: "Page " + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(pages)),
+		}
+	}
+	return page
+}
+
+// sameEmoji compares unicode emojis, ignoring the variation selector some clients leave out
+func sameEmoji(a, b string) bool {
+	return strings.TrimSuffix(a, "\ufe0f") == strings.TrimSuffix(b, "\ufe0f")
+}
+
+// SendPaginatedEmbed sends the first page to the channel, and flips the pages as users press the arrow reactions
+// until the timeout of the config, or the context is done. The reaction of the user is removed after every flip,
+// which requires the MANAGE_MESSAGES permission; otherwise users must remove their reaction before pressing the
+// arrow again. The arrows are not added if there is only one page. The config may be nil.
+func (c *Client) SendPaginatedEmbed(ctx context.Context, channelID Snowflake, pages []*ChannelEmbed, conf *PaginatorConfig) (p *Paginator, err error) {
+	if len(pages) == 0 {
+		return nil, errors.New("at least one page is required")
+	}
+	if conf == nil {
+		conf = &PaginatorConfig{}
+	}
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = paginatorTimeout
+	}
+
+	rest := c.WithContext(ctx)
+	msg, err := rest.CreateChannelMessage(channelID, &CreateChannelMessageParams{
+		Embed: paginatorPage(pages, 0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	p = &Paginator{
+		Message: msg,
+		pages:   pages,
+		flipped: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if len(pages) == 1 {
+		close(p.done)
+		return p, nil
+	}
+
+	// reactions are collected before the arrows are added, as users may press them as soon as they show up
+	var botID Snowflake
+	if msg.Author != nil {
+		botID = msg.Author.ID
+	}
+	p.collector = c.CollectReactions(ctx, msg.ID, &ReactionCollectorConfig{
+		Duration: timeout,
+		Filter: func(reaction *MessageReactionAdd) bool {
+			if reaction.UserID == botID || reaction.PartialEmoji == nil {
+				return false
+			}
+			if !conf.UserID.Empty() && reaction.UserID != conf.UserID {
+				return false
+			}
+			return sameEmoji(reaction.PartialEmoji.Name, PaginatorPrevious) || sameEmoji(reaction.PartialEmoji.Name, PaginatorNext)
+		},
+		Handler: p.handle,
+	})
+
+	for _, emoji := range []string{PaginatorPrevious, PaginatorNext} {
+		if _, err = rest.CreateReaction(channelID, msg.ID, emoji); err != nil {
+			p.collector.Stop()
+			return nil, err
+		}
+	}
+
+	go func() {
+		defer close(p.done)
+		p.update(c)
+
+		for _, emoji := range []string{PaginatorPrevious, PaginatorNext} {
+			c.DeleteOwnReaction(channelID, msg.ID, emoji)
+		}
+	}()
+	return p, nil
+}
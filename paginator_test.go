@@ -0,0 +1,93 @@
+package disgord
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPaginator(t *testing.T) {
+	pages := []*ChannelEmbed{
+		{Title: "first"},
+		{Title: "second", Footer: &ChannelEmbedFooter{Text: "custom"}},
+		{Title: "third"},
+	}
+
+	if footer := paginatorPage(pages, 0).Footer; footer == nil || footer.Text != "Page 1/3" {
+		t.Errorf("expected the page number as the footer, got %+v", footer)
+	}
+	if footer := paginatorPage(pages, 1).Footer; footer.Text != "custom" {
+		t.Errorf("expected the footer of the page to be kept, got %s", footer.Text)
+	}
+	if pages[0].Footer != nil {
+		t.Error("expected the pages to be left untouched")
+	}
+	if footer := paginatorPage(pages[:1], 0).Footer; footer != nil {
+		t.Errorf("expected no page number for a single page, got %+v", footer)
+	}
+
+	p := &Paginator{pages: pages}
+	if page := p.flip(false); page != 2 {
+		t.Errorf("expected to wrap around to the last page, got %d", page)
+	}
+	if page := p.flip(true); page != 0 {
+		t.Errorf("expected to wrap around to the first page, got %d", page)
+	}
+
+	p.flipped = make(chan struct{}, 1)
+	p.handle(&MessageReactionAdd{UserID: 1, PartialEmoji: &Emoji{Name: PaginatorNext}})
+	p.handle(&MessageReactionAdd{UserID: 2, PartialEmoji: &Emoji{Name: PaginatorNext}})
+	if p.page != 2 || len(p.removals) != 2 || len(p.flipped) != 1 {
+		t.Errorf("expected both flips to be left for a single update, got page %d and %d removals", p.page, len(p.removals))
+	}
+
+	if !sameEmoji("➡", PaginatorNext) || sameEmoji(PaginatorPrevious, PaginatorNext) {
+		t.Error("expected the variation selector to be ignored")
+	}
+}
+
+func TestPaginator_update(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		if r.Method == http.MethodPatch {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"2","channel_id":"1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+
+	conf := newTestConfig()
+	conf.HTTPClient = &http.Client{Transport: &redirectTransport{url: srvURL}}
+	session, err := NewSession(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the collection ended right after the handler flipped the page
+	p := &Paginator{
+		Message:   &Message{ID: 2, ChannelID: 1},
+		pages:     []*ChannelEmbed{{Title: "first"}, {Title: "second"}},
+		flipped:   make(chan struct{}, 1),
+		collector: &ReactionCollector{done: make(chan struct{})},
+	}
+	p.handle(&MessageReactionAdd{UserID: 3, PartialEmoji: &Emoji{Name: PaginatorNext}})
+	close(p.collector.done)
+	p.update(session.(*Client))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 || !strings.HasPrefix(requests[0], "PATCH /api/v6/channels/1/messages/2") || !strings.HasPrefix(requests[1], "DELETE ") {
+		t.Errorf("expected the last flip to be shown and the reaction removed, got %v", requests)
+	}
+}
@@ -0,0 +1,108 @@
+package disgord
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// reactionCollectorBuffer is the number of reactions held until the collector handles them. Reactions beyond it
+// are dropped.
+const reactionCollectorBuffer = 32
+
+// ReactionCollectorConfig decides which reactions are collected, and for how long
+type ReactionCollectorConfig struct {
+	// Filter decides which reactions are collected. Every reaction, including those of the bot, is collected if
+	// nil. Filters must return quickly, see Client.WaitFor.
+	Filter func(reaction *MessageReactionAdd) bool
+
+	// Handler is called for every collected reaction, in the order they are received. It runs on the collector
+	// goroutine, and reactions are dropped while more than 32 are waiting to be handled, so slow work such as
+	// REST calls should not be done here.
+	Handler func(reaction *MessageReactionAdd)
+
+	// Duration is how long reactions are collected. Zero collects until the collector is stopped, the limit is
+	// reached or the context is done.
+	Duration time.Duration
+
+	// Limit is the number of reactions collected before the collector stops. Zero is unlimited.
+	Limit int
+}
+
+// ReactionCollector gathers the reactions added to a message, see Client.CollectReactions
+type ReactionCollector struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	// reactions must only be read once done is closed
+	reactions []*MessageReactionAdd
+}
+
+// Stop ends the collection
+func (r *ReactionCollector) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// Done is closed once the collection has ended
+func (r *ReactionCollector) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait blocks until the collection has ended, and returns the collected reactions
+func (r *ReactionCollector) Wait() []*MessageReactionAdd {
+	<-r.done
+	return r.reactions
+}
+
+// CollectReactions gathers the reactions added to the message, until the duration or limit of the config is
+// reached, the collector is stopped or the context is done. The config may be nil.
+func (c *Client) CollectReactions(ctx context.Context, messageID Snowflake, conf *ReactionCollectorConfig) *ReactionCollector {
+	if conf == nil {
+		conf = &ReactionCollectorConfig{}
+	}
+
+	var cancel context.CancelFunc
+	if conf.Duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, conf.Duration)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	collector := &ReactionCollector{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	waiter := c.addWaiter(EventMessageReactionAdd, func(box interface{}) bool {
+		reaction := box.(*MessageReactionAdd)
+		return reaction.MessageID == messageID && (conf.Filter == nil || conf.Filter(reaction))
+	}, reactionCollectorBuffer)
+
+	go func() {
+		defer close(collector.done)
+		defer c.removeWaiter(waiter)
+		defer cancel()
+
+		for {
+			select {
+			case box := <-waiter.match:
+				reaction := box.(*MessageReactionAdd)
+				collector.reactions = append(collector.reactions, reaction)
+				if conf.Handler != nil {
+					conf.Handler(reaction)
+				}
+				if conf.Limit > 0 && len(collector.reactions) >= conf.Limit {
+					return
+				}
+			case <-collector.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return collector
+}
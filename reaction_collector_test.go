@@ -0,0 +1,69 @@
+package disgord
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClient_CollectReactions(t *testing.T) {
+	mngr := newTestShardManager(&ShardConfig{})
+	c := &Client{
+		shardMngr:   mngr,
+		evtDispatch: NewDispatch(mngr),
+	}
+	c.evtDispatch.start()
+	defer c.evtDispatch.stop()
+
+	react := func(messageID, userID Snowflake) {
+		c.evtDispatch.dispatch(context.Background(), EventMessageReactionAdd, c, &MessageReactionAdd{
+			MessageID:    messageID,
+			UserID:       userID,
+			PartialEmoji: &Emoji{Name: "👍"},
+		})
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		var handled int
+		collector := c.CollectReactions(context.Background(), 1, &ReactionCollectorConfig{
+			Filter: func(reaction *MessageReactionAdd) bool {
+				return reaction.UserID != 10
+			},
+			Handler: func(reaction *MessageReactionAdd) {
+				handled++
+			},
+			Duration: time.Second,
+			Limit:    2,
+		})
+		react(1, 10)
+		react(2, 11)
+		react(1, 11)
+		react(1, 12)
+
+		reactions := collector.Wait()
+		if len(reactions) != 2 || reactions[0].UserID != 11 || reactions[1].UserID != 12 {
+			t.Errorf("expected the reactions of user 11 and 12, got %+v", reactions)
+		}
+		if handled != 2 {
+			t.Errorf("expected the handler to be called twice, got %d", handled)
+		}
+		if mngr.tracksEvent(EventMessageReactionAdd) {
+			t.Error("expected the event to be removed once the collection ended")
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		collector := c.CollectReactions(context.Background(), 1, nil)
+		react(1, 10)
+		collector.Stop()
+
+		select {
+		case <-collector.Done():
+		case <-time.After(time.Second):
+			t.Fatal("expected the collection to end")
+		}
+		if reactions := collector.Wait(); len(reactions) > 1 {
+			t.Errorf("expected at most one reaction, got %d", len(reactions))
+		}
+	})
+}
@@ -582,7 +582,13 @@ type CreateChannelMessageParams struct {
 }
 
 func (p *CreateChannelMessageParams) prepare() (postBody interface{}, contentType string, err error) {
-	if len(p.Files) == 0 {
+	return prepareFiles(p, p.Files)
+}
+
+// prepareFiles creates the request body of a JSON payload. When files are given, the body is a multipart blob holding
+// the files and the JSON payload as the payload_json field.
+func prepareFiles(p interface{}, files []CreateChannelMessageFileParams) (postBody interface{}, contentType string, err error) {
+	if len(files) == 0 {
 		postBody = p
 		contentType = httd.ContentTypeJSON
 		return
@@ -603,7 +609,7 @@ func (p *CreateChannelMessageParams) prepare() (postBody interface{}, contentTyp
 	}
 
 	// Iterate through all the files and write them to the multipart blob
-	for i, file := range p.Files {
+	for i, file := range files {
 		if err = file.write(i, mp); err != nil {
 			return
 		}
@@ -320,15 +320,28 @@ type ExecuteWebhookParams struct {
 	WebhookID Snowflake `json:"-"`
 	Token     string    `json:"-"`
 
-	Content   string          `json:"content"`
-	Username  string          `json:"username"`
-	AvatarURL string          `json:"avatar_url"`
-	TTS       bool            `json:"tts"`
-	File      interface{}     `json:"file"`
-	Embeds    []*ChannelEmbed `json:"embeds"`
+	Content   string          `json:"content,omitempty"`
+	Username  string          `json:"username,omitempty"`   // overrides the default username of the webhook
+	AvatarURL string          `json:"avatar_url,omitempty"` // overrides the default avatar of the webhook
+	TTS       bool            `json:"tts,omitempty"`
+	Embeds    []*ChannelEmbed `json:"embeds,omitempty"`
+
+	Files []CreateChannelMessageFileParams `json:"-"` // Always omit as this is included in multipart, not JSON payload
+
+	// Payload is sent as the JSON body instead of the params above, such as the Slack or GitHub payload of the
+	// compatible endpoints. Use json.RawMessage to send it as is.
+	Payload interface{} `json:"-"`
+}
+
+func (p *ExecuteWebhookParams) prepare() (postBody interface{}, contentType string, err error) {
+	if p.Payload != nil {
+		return p.Payload, httd.ContentTypeJSON, nil
+	}
+	return prepareFiles(p, p.Files)
 }
 
-// ExecuteWebhook [REST] Trigger a webhook in Discord.
+// ExecuteWebhook [REST] Trigger a webhook in Discord. When wait is true, Discord waits for the message to be
+// created and the message is returned. Otherwise, or when a URLSuffix is given, the returned message is nil.
 //  Method                  POST
 //  Endpoint                /webhooks/{webhook.id}/{webhook.token}
 //  Rate limiter            /webhooks/{webhook.id}
@@ -336,24 +349,51 @@ type ExecuteWebhookParams struct {
 //  Reviewed                2018-08-14
 //  Comment                 This endpoint. supports both JSON and form data bodies. It does require
 //                          multipart/form-data requests instead of the normal JSON request type when
-//                          uploading files. Make sure you set your Content-Type to multipart/form-data if
-//                          you're doing that. Note that in that case, the embeds field cannot be used, but
-//                          you can pass an url-encoded JSON body as a form value for payload_json.
+//                          uploading files. The files of the params are uploaded this way, with the rest of
+//                          the params, embeds included, as the payload_json form value.
 //  Comment#2               For the webhook embed objects, you can set every field except type (it will be
 //                          rich regardless of if you try to set it), provider, video, and any height, width,
 //                          or proxy_url values for images.
-// TODO
-func ExecuteWebhook(client httd.Poster, params *ExecuteWebhookParams, wait bool, URLSuffix string) (err error) {
-	_, _, err = client.Post(&httd.Request{
+func ExecuteWebhook(client httd.Poster, params *ExecuteWebhookParams, wait bool, URLSuffix string) (ret *Message, err error) {
+	if params == nil {
+		err = errors.New("params can not be nil")
+		return
+	}
+	if params.WebhookID.Empty() {
+		err = newErrorMissingSnowflake("webhook id is missing")
+		return
+	}
+	if params.Token == "" {
+		err = errors.New("webhook token is missing")
+		return
+	}
+	if params.Payload == nil && params.Content == "" && len(params.Embeds) == 0 && len(params.Files) == 0 {
+		err = errors.New("the webhook message must have content, embeds, files or a payload")
+		return
+	}
+
+	postBody, contentType, err := params.prepare()
+	if err != nil {
+		return
+	}
+
+	e := endpoint.WebhookToken(params.WebhookID, params.Token) + URLSuffix
+	if wait {
+		e += "?wait=true"
+	}
+	_, body, err := client.Post(&httd.Request{
 		Ratelimiter: ratelimitWebhook(params.WebhookID),
-		Endpoint:    endpoint.WebhookToken(params.WebhookID, params.Token) + URLSuffix,
-		ContentType: httd.ContentTypeJSON,
+		Endpoint:    e,
+		Body:        postBody,
+		ContentType: contentType,
 	})
-	if err != nil {
+	if err != nil || !wait || URLSuffix != "" {
+		// the Slack and GitHub compatible endpoints do not respond with the message
 		return
 	}
 
-	//err = unmarshal(body, ret) // TODO: how to verify success?
+	ret = &Message{}
+	err = unmarshal(body, ret)
 	return
 }
 
@@ -365,8 +405,10 @@ func ExecuteWebhook(client httd.Poster, params *ExecuteWebhookParams, wait bool,
 //  Reviewed                2018-08-14
 //  Comment                 Refer to Slack's documentation for more information. We do not support Slack's channel,
 //                          icon_emoji, mrkdwn, or mrkdwn_in properties.
+//  Comment#2               The Slack payload is given as the Payload of the params.
 func ExecuteSlackWebhook(client httd.Poster, params *ExecuteWebhookParams, wait bool) (err error) {
-	return ExecuteWebhook(client, params, wait, endpoint.Slack())
+	_, err = ExecuteWebhook(client, params, wait, endpoint.Slack())
+	return
 }
 
 // ExecuteGitHubWebhook [REST] Trigger a webhook in Discord from the GitHub app.
@@ -379,6 +421,92 @@ func ExecuteSlackWebhook(client httd.Poster, params *ExecuteWebhookParams, wait
 //                          as the "Payload URL." You can choose what events your Discord channel receives by
 //                          choosing the "Let me select individual events" option and selecting individual
 //                          events for the new webhook you're configuring.
+//  Comment#2               The GitHub payload is given as the Payload of the params.
 func ExecuteGitHubWebhook(client httd.Poster, params *ExecuteWebhookParams, wait bool) (err error) {
-	return ExecuteWebhook(client, params, wait, endpoint.GitHub())
+	_, err = ExecuteWebhook(client, params, wait, endpoint.GitHub())
+	return
+}
+
+// EditWebhookMessageParams JSON params for func EditWebhookMessage
+type EditWebhookMessageParams struct {
+	Content string          `json:"content,omitempty"`
+	Embeds  []*ChannelEmbed `json:"embeds,omitempty"`
+}
+
+// EditWebhookMessage [REST] Edits a message previously sent by the webhook. Returns the updated message object on
+// success.
+//  Method                  PATCH
+//  Endpoint                /webhooks/{webhook.id}/{webhook.token}/messages/{message.id}
+//  Rate limiter            /webhooks/{webhook.id}
+//  Discord documentation   https://discordapp.com/developers/docs/resources/webhook#edit-webhook-message
+//  Reviewed                2026-10-16
+//  Comment                 -
+func EditWebhookMessage(client httd.Patcher, id Snowflake, token string, msgID Snowflake, params *EditWebhookMessageParams) (ret *Message, err error) {
+	if id.Empty() {
+		err = newErrorMissingSnowflake("webhook id is missing")
+		return
+	}
+	if token == "" {
+		err = errors.New("webhook token is missing")
+		return
+	}
+	if msgID.Empty() {
+		err = newErrorMissingSnowflake("message id is missing")
+		return
+	}
+	if params == nil {
+		err = errors.New("params can not be nil")
+		return
+	}
+
+	_, body, err := client.Patch(&httd.Request{
+		Ratelimiter: ratelimitWebhook(id),
+		Endpoint:    endpoint.WebhookMessage(id, token, msgID),
+		Body:        params,
+		ContentType: httd.ContentTypeJSON,
+	})
+	if err != nil {
+		return
+	}
+
+	ret = &Message{}
+	err = unmarshal(body, ret)
+	return
+}
+
+// DeleteWebhookMessage [REST] Deletes a message previously sent by the webhook. Returns a 204 NO CONTENT response
+// on success.
+//  Method                  DELETE
+//  Endpoint                /webhooks/{webhook.id}/{webhook.token}/messages/{message.id}
+//  Rate limiter            /webhooks/{webhook.id}
+//  Discord documentation   https://discordapp.com/developers/docs/resources/webhook#delete-webhook-message
+//  Reviewed                2026-10-16
+//  Comment                 -
+func DeleteWebhookMessage(client httd.Deleter, id Snowflake, token string, msgID Snowflake) (err error) {
+	if id.Empty() {
+		err = newErrorMissingSnowflake("webhook id is missing")
+		return
+	}
+	if token == "" {
+		err = errors.New("webhook token is missing")
+		return
+	}
+	if msgID.Empty() {
+		err = newErrorMissingSnowflake("message id is missing")
+		return
+	}
+
+	resp, _, err := client.Delete(&httd.Request{
+		Ratelimiter: ratelimitWebhook(id),
+		Endpoint:    endpoint.WebhookMessage(id, token, msgID),
+	})
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		msg := "unexpected http response code. Got " + resp.Status + ", wants " + http.StatusText(http.StatusNoContent)
+		err = errors.New(msg)
+	}
+	return
 }
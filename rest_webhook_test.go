@@ -1,8 +1,14 @@
 package disgord
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/andersfylling/disgord/httd"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -95,3 +101,156 @@ func TestModifyWebhook(t *testing.T) {
 		notContain(t, partial, "name")
 	})
 }
+
+// webhookRequester records the last request, and responds with the given status and body
+type webhookRequester struct {
+	req    *httd.Request
+	status int
+	body   []byte
+}
+
+func (r *webhookRequester) do(req *httd.Request) (*http.Response, []byte, error) {
+	r.req = req
+	return &http.Response{StatusCode: r.status, Status: http.StatusText(r.status)}, r.body, nil
+}
+
+func (r *webhookRequester) Post(req *httd.Request) (*http.Response, []byte, error) {
+	return r.do(req)
+}
+
+func (r *webhookRequester) Patch(req *httd.Request) (*http.Response, []byte, error) {
+	return r.do(req)
+}
+
+func (r *webhookRequester) Delete(req *httd.Request) (*http.Response, []byte, error) {
+	return r.do(req)
+}
+
+func TestExecuteWebhook(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		client := &webhookRequester{status: http.StatusNoContent}
+		params := &ExecuteWebhookParams{WebhookID: 1, Token: "token", Content: "build passed"}
+		msg, err := ExecuteWebhook(client, params, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg != nil {
+			t.Error("expected no message without wait")
+		}
+		if client.req.Endpoint != "/webhooks/1/token" {
+			t.Errorf("unexpected endpoint %s", client.req.Endpoint)
+		}
+		if client.req.ContentType != httd.ContentTypeJSON || client.req.Body != params {
+			t.Errorf("expected the params as the JSON body, got %s %v", client.req.ContentType, client.req.Body)
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		client := &webhookRequester{status: http.StatusOK, body: []byte(`{"id":"5","content":"artifacts"}`)}
+		params := &ExecuteWebhookParams{
+			WebhookID: 1,
+			Token:     "token",
+			Content:   "artifacts",
+			Files: []CreateChannelMessageFileParams{
+				{Reader: strings.NewReader("binary"), FileName: "build.zip"},
+			},
+		}
+		msg, err := ExecuteWebhook(client, params, true, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil || msg.ID != 5 {
+			t.Errorf("expected the created message, got %+v", msg)
+		}
+		if client.req.Endpoint != "/webhooks/1/token?wait=true" {
+			t.Errorf("unexpected endpoint %s", client.req.Endpoint)
+		}
+
+		mediaType, mediaParams, err := mime.ParseMediaType(client.req.ContentType)
+		if err != nil || mediaType != "multipart/form-data" {
+			t.Fatalf("expected a multipart body, got %s", client.req.ContentType)
+		}
+		form, err := multipart.NewReader(client.req.Body.(*bytes.Buffer), mediaParams["boundary"]).ReadForm(1 << 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload := form.Value["payload_json"]; len(payload) != 1 || !strings.Contains(payload[0], `"content":"artifacts"`) {
+			t.Errorf("unexpected payload_json %v", payload)
+		}
+		files := form.File["file0"]
+		if len(files) != 1 || files[0].Filename != "build.zip" {
+			t.Fatalf("expected the file to be uploaded, got %v", files)
+		}
+		f, err := files[0].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if content, _ := ioutil.ReadAll(f); string(content) != "binary" {
+			t.Errorf("unexpected file content %s", content)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		client := &webhookRequester{}
+		if _, err := ExecuteWebhook(client, &ExecuteWebhookParams{WebhookID: 1, Token: "token"}, false, ""); err == nil {
+			t.Error("expected an empty message to be refused")
+		}
+		if client.req != nil {
+			t.Error("expected no request to be sent")
+		}
+
+		if err := ExecuteSlackWebhook(client, &ExecuteWebhookParams{WebhookID: 1, Token: "token"}, false); err == nil {
+			t.Error("expected an empty Slack message to be refused")
+		}
+		if client.req != nil {
+			t.Error("expected no request to be sent")
+		}
+	})
+
+	t.Run("payload", func(t *testing.T) {
+		client := &webhookRequester{status: http.StatusOK}
+		payload := json.RawMessage(`{"text":"build passed"}`)
+		params := &ExecuteWebhookParams{WebhookID: 1, Token: "token", Payload: payload}
+		if err := ExecuteSlackWebhook(client, params, true); err != nil {
+			t.Fatal(err)
+		}
+		if client.req.Endpoint != "/webhooks/1/token/slack?wait=true" {
+			t.Errorf("unexpected endpoint %s", client.req.Endpoint)
+		}
+		if body, ok := client.req.Body.(json.RawMessage); !ok || string(body) != string(payload) {
+			t.Errorf("expected the payload as the JSON body, got %v", client.req.Body)
+		}
+	})
+}
+
+func TestWebhookMessage(t *testing.T) {
+	client := &webhookRequester{status: http.StatusOK, body: []byte(`{"id":"5","content":"edited"}`)}
+	msg, err := EditWebhookMessage(client, 1, "token", 5, &EditWebhookMessageParams{Content: "edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "edited" || client.req.Endpoint != "/webhooks/1/token/messages/5" {
+		t.Errorf("unexpected message %+v from %s", msg, client.req.Endpoint)
+	}
+
+	client.status = http.StatusNoContent
+	if err = DeleteWebhookMessage(client, 1, "token", 5); err != nil {
+		t.Error(err)
+	}
+	client.status = http.StatusNotFound
+	if err = DeleteWebhookMessage(client, 1, "token", 5); err == nil {
+		t.Error("expected an error for an unexpected status code")
+	}
+
+	client.req = nil
+	if _, err = EditWebhookMessage(client, 1, "", 5, &EditWebhookMessageParams{}); err == nil {
+		t.Error("expected an edit without a token to be refused")
+	}
+	if err = DeleteWebhookMessage(client, 1, "", 5); err == nil {
+		t.Error("expected a delete without a token to be refused")
+	}
+	if client.req != nil {
+		t.Error("expected no request to be sent")
+	}
+}
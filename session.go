@@ -1,7 +1,11 @@
 package disgord
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/andersfylling/disgord/constant"
@@ -20,6 +24,8 @@ func NewRESTClient(conf *Config) (client *httd.Client) {
 		UserAgentVersion:             constant.Version,
 		HTTPClient:                   conf.HTTPClient,
 		CancelRequestWhenRateLimited: conf.CancelRequestWhenRateLimited,
+		RetryPolicy:                  conf.RetryPolicy,
+		RateLimiter:                  conf.RateLimiter,
 	}
 	client = httd.NewClient(reqConf)
 	return
@@ -37,33 +43,65 @@ func NewSession(conf *Config) (Session, error) {
 	if conf.ProjectName == "" {
 		conf.ProjectName = LibraryInfo()
 	}
-	dws, err := websocket.NewClient(&websocket.Config{
+
+	// request client
+	reqClient := NewRESTClient(conf)
+
+	// shards
+	if conf.ShardConfig.TotalShards == 0 && conf.TotalShards > 0 {
+		conf.ShardConfig.ShardIDs = []uint{conf.ShardID}
+		conf.ShardConfig.TotalShards = conf.TotalShards
+	}
+	if conf.ShardConfig.URL == "" {
+		conf.ShardConfig.URL = conf.WebsocketURL
+	}
+
+	if conf.GuildLargeThreshold == 0 {
+		conf.GuildLargeThreshold = 250
+	}
+	if conf.GuildLargeThreshold < 50 || conf.GuildLargeThreshold > 250 {
+		return nil, errors.New("GuildLargeThreshold must be between 50 and 250, got " + strconv.Itoa(int(conf.GuildLargeThreshold)))
+	}
+
+	var presence interface{}
+	if conf.Presence != nil {
+		if err := conf.Presence.validate(); err != nil {
+			return nil, err
+		}
+		presence = conf.Presence
+	}
+
+	var compression string
+	if conf.CompressGateway {
+		compression = websocket.CompressionZlibStream
+	}
+	if conf.GatewayEncoding == "" {
+		conf.GatewayEncoding = constant.JSONEncoding
+	}
+
+	shardMngr := NewShardManager(&conf.ShardConfig, &websocket.Config{
 		// identity
 		Browser:             LibraryInfo(),
 		Device:              conf.ProjectName,
-		GuildLargeThreshold: 250, // TODO: config
-		ShardID:             conf.ShardID,
-		ShardCount:          conf.TotalShards,
+		GuildLargeThreshold: conf.GuildLargeThreshold,
 
 		// lib specific
 		Version:       constant.DiscordVersion,
-		Encoding:      constant.JSONEncoding,
+		Encoding:      conf.GatewayEncoding,
 		ChannelBuffer: 1,
-		Endpoint:      conf.WebsocketURL,
 
 		// user settings
-		Token:      conf.Token,
-		HTTPClient: conf.HTTPClient,
-	})
-	if err != nil {
-		return nil, err
-	}
+		Token:           conf.Token,
+		HTTPClient:      conf.HTTPClient,
+		Compression:     compression,
+		ReconnectPolicy: conf.ReconnectPolicy,
+		Presence:        presence,
 
-	// request client
-	reqClient := NewRESTClient(conf)
+		RejectRateLimitedCommands: conf.RejectRateLimitedCommands,
+	}, reqClient)
 
 	// event dispatcher
-	evtDispatcher := NewDispatch(dws)
+	evtDispatcher := NewDispatch(shardMngr)
 
 	// caching
 	if conf.CacheConfig == nil {
@@ -86,43 +124,52 @@ func NewSession(conf *Config) (Session, error) {
 
 	// register for events for activate caches
 	if !conf.CacheConfig.DisableUserCaching {
-		dws.RegisterEvent(event.Ready)
-		dws.RegisterEvent(event.UserUpdate)
+		shardMngr.RegisterEvent(event.Ready)
+		shardMngr.RegisterEvent(event.UserUpdate)
 	}
 	if !conf.CacheConfig.DisableVoiceStateCaching {
-		dws.RegisterEvent(event.VoiceStateUpdate)
+		shardMngr.RegisterEvent(event.VoiceStateUpdate)
 	}
 	if !conf.CacheConfig.DisableChannelCaching {
-		dws.RegisterEvent(event.ChannelCreate)
-		dws.RegisterEvent(event.ChannelUpdate)
-		dws.RegisterEvent(event.ChannelPinsUpdate)
-		dws.RegisterEvent(event.ChannelDelete)
+		shardMngr.RegisterEvent(event.ChannelCreate)
+		shardMngr.RegisterEvent(event.ChannelUpdate)
+		shardMngr.RegisterEvent(event.ChannelPinsUpdate)
+		shardMngr.RegisterEvent(event.ChannelDelete)
+	}
+	if conf.CacheConfig.LoadOfflineMembers {
+		shardMngr.RegisterEvent(event.GuildCreate)
+		shardMngr.RegisterEvent(event.GuildMembersChunk)
 	}
 	if !conf.CacheConfig.DisableGuildCaching {
-		dws.RegisterEvent(event.GuildCreate)
-		dws.RegisterEvent(event.GuildDelete)
-		dws.RegisterEvent(event.GuildUpdate)
-		dws.RegisterEvent(event.GuildEmojisUpdate)
-		dws.RegisterEvent(event.GuildMemberAdd)
-		dws.RegisterEvent(event.GuildMemberRemove)
-		dws.RegisterEvent(event.GuildMembersChunk)
-		dws.RegisterEvent(event.GuildMemberUpdate)
-		dws.RegisterEvent(event.GuildRoleCreate)
-		dws.RegisterEvent(event.GuildRoleDelete)
-		dws.RegisterEvent(event.GuildRoleUpdate)
-		dws.RegisterEvent(event.GuildIntegrationsUpdate)
+		shardMngr.RegisterEvent(event.GuildCreate)
+		shardMngr.RegisterEvent(event.GuildDelete)
+		shardMngr.RegisterEvent(event.GuildUpdate)
+		shardMngr.RegisterEvent(event.GuildEmojisUpdate)
+		shardMngr.RegisterEvent(event.GuildMemberAdd)
+		shardMngr.RegisterEvent(event.GuildMemberRemove)
+		shardMngr.RegisterEvent(event.GuildMembersChunk)
+		shardMngr.RegisterEvent(event.GuildMemberUpdate)
+		shardMngr.RegisterEvent(event.GuildRoleCreate)
+		shardMngr.RegisterEvent(event.GuildRoleDelete)
+		shardMngr.RegisterEvent(event.GuildRoleUpdate)
+		shardMngr.RegisterEvent(event.GuildIntegrationsUpdate)
 	}
 
 	// create a disgord client/instance/session
 	c := &Client{
 		config:        conf,
 		httpClient:    conf.HTTPClient,
-		ws:            dws,
-		socketEvtChan: dws.EventChan(),
+		shardMngr:     shardMngr,
+		socketEvtChan: shardMngr.EventChan(),
 		token:         conf.Token,
 		evtDispatch:   evtDispatcher,
 		cache:         cacher,
 		req:           reqClient,
+
+		eventHandlerOnce: &sync.Once{},
+		memberRequests:   &guildMembersRequests{},
+		offlineMembers:   &offlineMembersLoader{},
+		membersLoaded:    make(chan *GuildMembersLoaded),
 	}
 
 	return c, nil
@@ -187,8 +234,14 @@ type SocketHandler interface {
 
 	// event handlers
 	On(event string, handler ...interface{})
+	Use(middlewares ...Middleware)
+	UseOn(event string, middlewares ...Middleware)
+	WaitFor(ctx context.Context, event string, predicate func(box interface{}) bool) (box interface{}, err error)
+	CollectReactions(ctx context.Context, messageID Snowflake, conf *ReactionCollectorConfig) *ReactionCollector
+	SendPaginatedEmbed(ctx context.Context, channelID Snowflake, pages []*ChannelEmbed, conf *PaginatorConfig) (*Paginator, error)
 	Emit(command SocketCommand, dataPointer interface{})
-	//Use(middleware ...interface{}) // TODO: is this useful?
+	UpdateStatus(status string, activity *Activity) error
+	RequestGuildMembers(ctx context.Context, cmd *RequestGuildMembersCommand) ([]*Member, error)
 
 	// event channels
 	EventChan(event string) (channel interface{}, err error)
@@ -201,6 +254,7 @@ type SocketHandler interface {
 
 	ShardID() uint
 	ShardIDString() string
+	ShardManager() *ShardManager
 }
 
 // AuditLogsRESTer REST interface for all audit-logs endpoints
@@ -319,9 +373,11 @@ type WebhookRESTer interface {
 	ModifyWebhookWithToken(newWebhook *Webhook) (ret *Webhook, err error)
 	DeleteWebhook(webhookID Snowflake) (err error)
 	DeleteWebhookWithToken(id Snowflake, token string) (err error)
-	ExecuteWebhook(params *ExecuteWebhookParams, wait bool, URLSuffix string) (err error)
+	ExecuteWebhook(params *ExecuteWebhookParams, wait bool, URLSuffix string) (ret *Message, err error)
 	ExecuteSlackWebhook(params *ExecuteWebhookParams, wait bool) (err error)
 	ExecuteGitHubWebhook(params *ExecuteWebhookParams, wait bool) (err error)
+	EditWebhookMessage(id Snowflake, token string, msgID Snowflake, params *EditWebhookMessageParams) (ret *Message, err error)
+	DeleteWebhookMessage(id Snowflake, token string, msgID Snowflake) (err error)
 }
 
 // RESTer holds all the sub REST interfaces
@@ -336,6 +392,11 @@ type RESTer interface {
 	WebhookRESTer
 }
 
+// ContextRESTer gives access to every REST method bound to a context, such that requests can be cancelled
+type ContextRESTer interface {
+	WithContext(ctx context.Context) RESTer
+}
+
 // Session The main interface for Disgord
 type Session interface {
 	// give information about the bot/connected user
@@ -365,21 +426,11 @@ type Session interface {
 	// state/caching module
 	// checks the cache first, otherwise do a http request
 	RESTer
+	ContextRESTer
 
 	// Custom REST functions
 	SendMsg(channelID Snowflake, message *Message) (msg *Message, err error)
 	SendMsgString(channelID Snowflake, content string) (msg *Message, err error)
 	UpdateMessage(message *Message) (msg *Message, err error)
 	UpdateChannel(channel *Channel) (err error)
-
-	// same as above. Except these returns a channel
-	// WARNING: none below should be assumed to be working.
-	// TODO: implement in the future!
-	//GuildChan(guildID Snowflake) <-chan *Guild
-	//ChannelChan(channelID Snowflake) <-chan *Channel
-	//ChannelsChan(guildID Snowflake) <-chan map[Snowflake]*Channel
-	//MsgChan(msgID Snowflake) <-chan *Message
-	//UserChan(userID Snowflake) <-chan *UserChan
-	//MemberChan(guildID, userID Snowflake) <-chan *Member
-	//MembersChan(guildID Snowflake) <-chan map[Snowflake]*Member
 }
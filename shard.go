@@ -0,0 +1,466 @@
+package disgord
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/disgord/websocket"
+	wsevent "github.com/andersfylling/disgord/websocket/event"
+)
+
+// DiscordWebsocket is the socket layer a Dispatch registers events of interest to. Events that are not
+// registered are discarded at the socket level.
+type DiscordWebsocket interface {
+	RegisterEvent(event string)
+	RemoveEvent(event string)
+}
+
+// ShardConfig decides how the gateway connection is split into shards.
+// See https://discordapp.com/developers/docs/topics/gateway#sharding
+type ShardConfig struct {
+	// ShardIDs are the shards this session should run. If empty, every shard in the range [0, TotalShards)
+	// is run by this session.
+	ShardIDs []uint
+
+	// TotalShards is the number of shards the bot is split into, across every process. If zero, the number
+	// of shards recommended by Discord is retrieved from the Gateway Bot endpoint on Connect.
+	TotalShards uint
+
+	// URL for the Discord gateway. If empty, the URL returned by the Gateway Bot endpoint is used.
+	URL string
+
+	// ReadyTimeout is how long Connect waits for every shard to be ready, before it disconnects them and returns
+	// context.DeadlineExceeded. The identify limiter lets each max_concurrency bucket identify once every 5
+	// seconds, so it should grow with the number of shards per bucket. Zero waits until every shard is ready.
+	ReadyTimeout time.Duration
+
+	// SessionStore persists the gateway session of every shard, such that a restarted process resumes the
+	// sessions instead of identifying again. See websocket.NewFileSessionStore.
+	SessionStore websocket.SessionStore
+
+	// HandOffPath is a Unix socket used to hand the shard sessions over between processes during a deploy. On
+	// Connect, the sessions are taken over from the process listening on the socket, if any, which stops
+	// processing events. The socket is then served to the next process. See ShardManager.HandedOff.
+	HandOffPath string
+}
+
+// NewShardManager creates a shard manager which spawns one websocket client per shard using the given
+// websocket config as a template. No connections are established until Connect is called.
+func NewShardManager(conf *ShardConfig, wsConf *websocket.Config, rest httd.Getter) *ShardManager {
+	limiter := wsConf.IdentifyLimiter
+	if limiter == nil {
+		limiter = websocket.IdentifyLimiterFor(wsConf.Token)
+	}
+
+	return &ShardManager{
+		conf:      conf,
+		wsConf:    wsConf,
+		rest:      rest,
+		limiter:   limiter,
+		shards:    make(map[uint]*websocket.Client),
+		evtChan:   make(chan *websocket.Event),
+		handedOff: make(chan struct{}),
+	}
+}
+
+// ShardManager owns one websocket client per shard and merges their events into one event channel, such that
+// a single Session can hold every shard while sharing the same cache and REST client.
+type ShardManager struct {
+	sync.RWMutex
+	conf   *ShardConfig
+	wsConf *websocket.Config
+	rest   httd.Getter
+
+	limiter *websocket.IdentifyLimiter
+
+	shards  map[uint]*websocket.Client
+	evtChan chan *websocket.Event
+
+	handOffListener net.Listener
+	handedOff       chan struct{}
+
+	// trackedEvents holds the number of registrations of every event type
+	trackedEvents map[string]int
+}
+
+var _ DiscordWebsocket = (*ShardManager)(nil)
+
+// prepare retrieves the missing shard information from Discord and creates a websocket client for every
+// shard this manager should run.
+func (s *ShardManager) prepare() (err error) {
+	if s.conf.TotalShards == 0 || s.conf.URL == "" {
+		var gateway *GatewayBot
+		gateway, err = GetGatewayBot(s.rest)
+		if err != nil {
+			return
+		}
+		s.updateSessionStartLimit(gateway)
+
+		if s.conf.TotalShards == 0 {
+			s.conf.TotalShards = gateway.Shards
+		}
+		if s.conf.URL == "" {
+			s.conf.URL = gateway.URL + "?v=" + strconv.Itoa(s.wsConf.Version) + "&encoding=" + s.wsConf.Encoding
+		}
+	}
+	if s.conf.TotalShards == 0 {
+		err = errors.New("unable to decide the number of shards")
+		return
+	}
+
+	if len(s.conf.ShardIDs) == 0 {
+		for id := uint(0); id < s.conf.TotalShards; id++ {
+			s.conf.ShardIDs = append(s.conf.ShardIDs, id)
+		}
+	}
+
+	for _, id := range s.conf.ShardIDs {
+		if id >= s.conf.TotalShards {
+			err = errors.New("shard id " + strconv.Itoa(int(id)) + " is out of range, TotalShards is " + strconv.Itoa(int(s.conf.TotalShards)))
+			return
+		}
+	}
+
+	sessions, err := s.takeOver()
+	if err != nil {
+		return
+	}
+
+	// the shards are only kept once every client was created, such that a failed prepare can be retried
+	shards := make(map[uint]*websocket.Client, len(s.conf.ShardIDs))
+	for _, id := range s.conf.ShardIDs {
+		conf := *s.wsConf
+		conf.ShardID = id
+		conf.ShardCount = s.conf.TotalShards
+		conf.Endpoint = s.conf.URL
+		conf.SessionStore = s.conf.SessionStore
+		conf.IdentifyLimiter = s.limiter
+		if session, exists := sessions[id]; exists {
+			conf.Session = session
+		}
+
+		var shard *websocket.Client
+		shard, err = websocket.NewClient(&conf)
+		if err != nil {
+			return
+		}
+		for evt := range s.trackedEvents {
+			shard.RegisterEvent(evt)
+		}
+		shards[id] = shard
+	}
+
+	s.shards = shards
+	for _, shard := range shards {
+		go s.forwardEvents(shard)
+	}
+	return
+}
+
+// forwardEvents merges the events from a shard into the event channel of the manager
+func (s *ShardManager) forwardEvents(shard *websocket.Client) {
+	for evt := range shard.EventChan() {
+		s.evtChan <- evt
+	}
+}
+
+// updateSessionStartLimit hands the session start limit of the bot to the identify limiter
+func (s *ShardManager) updateSessionStartLimit(gateway *GatewayBot) {
+	limit := gateway.SessionStartLimit
+	s.limiter.Update(limit.Total, limit.Remaining, time.Duration(limit.ResetAfter)*time.Millisecond, limit.MaxConcurrency)
+}
+
+// Connect establishes a gateway connection for every shard, and waits until every shard is ready. The identifies
+// are queued by the identify limiter, which is updated with the session start limit of the bot first. If Discord
+// closes a connection for good, such as for an invalid token, every shard is disconnected and the
+// *websocket.ErrorFatalClose is returned. Likewise, the *websocket.ErrorIdentifyBudget is returned if a shard
+// could not identify because of the session start limit. See ShardConfig.ReadyTimeout.
+func (s *ShardManager) Connect() (err error) {
+	if err = s.connect(); err != nil {
+		return
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if s.conf.ReadyTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), s.conf.ReadyTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	ids := s.ShardIDs()
+	errs := make(chan error, len(ids))
+	for _, id := range ids {
+		shard, _ := s.Shard(id)
+		go func() {
+			errs <- shard.WaitForReady(ctx)
+		}()
+	}
+	for range ids {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			cancel()
+		}
+	}
+
+	if err != nil {
+		_ = s.Disconnect()
+	}
+	return
+}
+
+func (s *ShardManager) connect() (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.shards) == 0 {
+		if err = s.prepare(); err != nil {
+			return
+		}
+	}
+
+	if _, _, known := s.limiter.Remaining(); !known {
+		var gateway *GatewayBot
+		if gateway, err = GetGatewayBot(s.rest); err != nil {
+			return
+		}
+		s.updateSessionStartLimit(gateway)
+	}
+
+	for _, id := range s.conf.ShardIDs {
+		if err = s.shards[id].Connect(); err != nil {
+			return
+		}
+	}
+
+	return s.listenHandOff()
+}
+
+// IdentifyLimiter returns the identify limiter used by every shard. See websocket.IdentifyLimiter.Remaining
+// for the number of identifies left today.
+func (s *ShardManager) IdentifyLimiter() *websocket.IdentifyLimiter {
+	return s.limiter
+}
+
+// Disconnect closes the gateway connection for every shard, except the shards that were handed off
+func (s *ShardManager) Disconnect() (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.stopHandOff()
+	for _, id := range s.conf.ShardIDs {
+		shard, exists := s.shards[id]
+		if !exists || shard.State() == websocket.StateClosed {
+			continue
+		}
+
+		if e := shard.Disconnect(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// EventChan returns the channel every shard dispatches their events to
+func (s *ShardManager) EventChan() <-chan *websocket.Event {
+	return s.evtChan
+}
+
+// RegisterEvent tells every shard that the event type is of interest. Shards created after the event was
+// registered will also be notified. Every registration must be removed before the event is discarded again.
+func (s *ShardManager) RegisterEvent(event string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.trackedEvents == nil {
+		s.trackedEvents = make(map[string]int)
+	}
+	s.trackedEvents[event]++
+	if s.trackedEvents[event] > 1 {
+		return
+	}
+
+	for _, shard := range s.shards {
+		shard.RegisterEvent(event)
+	}
+}
+
+// tracksEvent checks if the event type is registered
+func (s *ShardManager) tracksEvent(event string) bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.trackedEvents[event] > 0
+}
+
+// RemoveEvent removes a registration of the event type. Once every registration is removed, the event is removed
+// from every shard, causing it to be discarded at the socket layer.
+func (s *ShardManager) RemoveEvent(event string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.trackedEvents[event] == 0 {
+		return
+	}
+	s.trackedEvents[event]--
+	if s.trackedEvents[event] > 0 {
+		return
+	}
+	delete(s.trackedEvents, event)
+
+	for _, shard := range s.shards {
+		shard.RemoveEvent(event)
+	}
+}
+
+// TotalShards returns the total number of shards the bot is split into. This is zero until Connect is called
+// if ShardConfig.TotalShards was not set.
+func (s *ShardManager) TotalShards() uint {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.conf.TotalShards
+}
+
+// ShardIDs returns the ID of every shard run by this manager, in ascending order.
+func (s *ShardManager) ShardIDs() (ids []uint) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for id := range s.shards {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return
+}
+
+// Shard returns the websocket client for the given shard ID
+func (s *ShardManager) Shard(id uint) (shard *websocket.Client, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var exists bool
+	if shard, exists = s.shards[id]; !exists {
+		err = errors.New("shard " + strconv.Itoa(int(id)) + " is not run by this shard manager")
+	}
+	return
+}
+
+// Snapshots returns the session of every shard, which can be used to resume the sessions in another process.
+// See websocket.SessionSnapshot.
+func (s *ShardManager) Snapshots() (snapshots []*websocket.SessionSnapshot) {
+	for _, id := range s.ShardIDs() {
+		if shard, err := s.Shard(id); err == nil {
+			snapshots = append(snapshots, shard.Snapshot())
+		}
+	}
+	return
+}
+
+// States returns the connection state of every shard
+func (s *ShardManager) States() (states map[uint]websocket.ConnectionState) {
+	states = make(map[uint]websocket.ConnectionState)
+	for _, id := range s.ShardIDs() {
+		if shard, err := s.Shard(id); err == nil {
+			states[id] = shard.State()
+		}
+	}
+	return
+}
+
+// ShardForGuildID returns the websocket client for the shard which receives events for the given guild.
+// See GetShardForGuildID.
+func (s *ShardManager) ShardForGuildID(guildID Snowflake) (shard *websocket.Client, err error) {
+	total := s.TotalShards()
+	if total == 0 {
+		err = errors.New("the number of shards is unknown until the shard manager has connected")
+		return
+	}
+
+	return s.Shard(GetShardForGuildID(guildID, total))
+}
+
+// socketCommands maps the SocketCommand names to the commands of the websocket package
+var socketCommands = map[SocketCommand]string{
+	CommandRequestGuildMembers: wsevent.RequestGuildMembers,
+	CommandUpdateVoiceState:    wsevent.VoiceStateUpdate,
+	CommandUpdateStatus:        wsevent.StatusUpdate,
+}
+
+// Emit sends the socket command to the shard of the guild referenced in the command payload. Commands that
+// are not bound to a guild, such as status updates, are sent to every shard.
+func (s *ShardManager) Emit(command string, data interface{}) (err error) {
+	if socketCommand, ok := socketCommands[command]; ok {
+		command = socketCommand
+	}
+
+	var guildID Snowflake
+	switch cmd := data.(type) {
+	case *RequestGuildMembersCommand:
+		guildID = cmd.GuildID
+	case RequestGuildMembersCommand:
+		guildID = cmd.GuildID
+	case *UpdateVoiceStateCommand:
+		guildID = cmd.GuildID
+	case UpdateVoiceStateCommand:
+		guildID = cmd.GuildID
+	}
+
+	if !guildID.Empty() {
+		var shard *websocket.Client
+		if shard, err = s.ShardForGuildID(guildID); err != nil {
+			return
+		}
+		return shard.Emit(command, data)
+	}
+
+	// the lock is not held while emitting, as a shard may wait for its command budget
+	s.RLock()
+	shards := make([]*websocket.Client, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard)
+	}
+	s.RUnlock()
+
+	for _, shard := range shards {
+		if e := shard.Emit(command, data); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// HeartbeatLatency returns the average heartbeat latency across all the shards that have determined one
+func (s *ShardManager) HeartbeatLatency() (duration time.Duration, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var total time.Duration
+	var count int64
+	for _, shard := range s.shards {
+		latency, e := shard.HeartbeatLatency()
+		if e != nil {
+			continue
+		}
+		total += latency
+		count++
+	}
+
+	if count == 0 {
+		err = errors.New("latency not determined yet")
+		return
+	}
+
+	duration = time.Duration(int64(total) / count)
+	return
+}
@@ -0,0 +1,116 @@
+package disgord
+
+import (
+	"net"
+	"os"
+
+	"github.com/andersfylling/disgord/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// takeOver retrieves the sessions of the shards from the process listening on ShardConfig.HandOffPath, if any.
+// Must be called with the lock held.
+func (s *ShardManager) takeOver() (sessions map[uint]*websocket.SessionSnapshot, err error) {
+	sessions = make(map[uint]*websocket.SessionSnapshot)
+	if s.conf.HandOffPath == "" {
+		return
+	}
+
+	snapshots, err := websocket.TakeOver(s.conf.HandOffPath, s.conf.ShardIDs, s.conf.TotalShards)
+	if err != nil {
+		return
+	}
+	for _, snapshot := range snapshots {
+		sessions[snapshot.ShardID] = snapshot
+	}
+	if len(snapshots) > 0 {
+		logrus.Infof("took over the sessions of %d shards", len(snapshots))
+	}
+	return
+}
+
+// listenHandOff starts serving the sessions to the next process on ShardConfig.HandOffPath. Must be called with
+// the lock held.
+func (s *ShardManager) listenHandOff() (err error) {
+	if s.conf.HandOffPath == "" || s.handOffListener != nil {
+		return
+	}
+
+	// the socket of the previous process is left behind, as the path now belongs to this process
+	if err = os.Remove(s.conf.HandOffPath); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	var l net.Listener
+	if l, err = net.Listen("unix", s.conf.HandOffPath); err != nil {
+		return
+	}
+	if unixListener, ok := l.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+
+	s.handOffListener = l
+	go s.serveHandOff(l)
+	return
+}
+
+// stopHandOff stops serving the sessions and removes the socket. Must be called with the lock held.
+func (s *ShardManager) stopHandOff() {
+	if s.handOffListener == nil {
+		return
+	}
+
+	s.handOffListener.Close()
+	s.handOffListener = nil
+	os.Remove(s.conf.HandOffPath)
+}
+
+func (s *ShardManager) serveHandOff(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.RLock()
+		shards := make(map[uint]*websocket.Client, len(s.shards))
+		for id, shard := range s.shards {
+			shards[id] = shard
+		}
+		s.RUnlock()
+
+		ids, err := websocket.HandOff(conn, shards)
+		if err != nil {
+			logrus.Error(err)
+		}
+		if len(ids) > 0 {
+			logrus.Infof("handed off the sessions of %d shards", len(ids))
+		}
+
+		remaining := 0
+		for _, shard := range shards {
+			if shard.State() != websocket.StateClosed {
+				remaining++
+			}
+		}
+		if remaining > 0 {
+			continue
+		}
+
+		// the socket belongs to the next process now
+		s.Lock()
+		if s.handOffListener == l {
+			s.handOffListener = nil
+		}
+		s.Unlock()
+		l.Close()
+		close(s.handedOff)
+		return
+	}
+}
+
+// HandedOff is closed once the session of every shard was handed off to the next process. See
+// ShardConfig.HandOffPath.
+func (s *ShardManager) HandedOff() <-chan struct{} {
+	return s.handedOff
+}
@@ -0,0 +1,135 @@
+package disgord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord/constant"
+	"github.com/andersfylling/disgord/websocket"
+	gorilla "github.com/gorilla/websocket"
+)
+
+func newTestShardManager(conf *ShardConfig) *ShardManager {
+	return NewShardManager(conf, &websocket.Config{
+		Version:  constant.DiscordVersion,
+		Encoding: constant.JSONEncoding,
+		Token:    "sifhsdoifhsdifhsdf",
+	}, nil)
+}
+
+func TestShardManager_prepare(t *testing.T) {
+	t.Run("all shards", func(t *testing.T) {
+		mngr := newTestShardManager(&ShardConfig{
+			TotalShards: 4,
+			URL:         "wss://localhost",
+		})
+		mngr.RegisterEvent(EventMessageCreate)
+		if err := mngr.prepare(); err != nil {
+			t.Fatal(err)
+		}
+
+		ids := mngr.ShardIDs()
+		if len(ids) != 4 {
+			t.Fatalf("expected 4 shards, got %d", len(ids))
+		}
+		for i, id := range ids {
+			if uint(i) != id {
+				t.Errorf("shard ids are not sorted. Got %d, wants %d", id, i)
+			}
+
+			shard, err := mngr.Shard(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !shard.TracksEvent(EventMessageCreate) {
+				t.Errorf("expected shard %d to track the events registered before it was created", id)
+			}
+		}
+	})
+
+	t.Run("subset of shards", func(t *testing.T) {
+		mngr := newTestShardManager(&ShardConfig{
+			ShardIDs:    []uint{2, 3},
+			TotalShards: 4,
+			URL:         "wss://localhost",
+		})
+		if err := mngr.prepare(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := mngr.Shard(1); err == nil {
+			t.Error("expected shard 1 to not exist")
+		}
+		if _, err := mngr.Shard(3); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("shard out of range", func(t *testing.T) {
+		mngr := newTestShardManager(&ShardConfig{
+			ShardIDs:    []uint{4},
+			TotalShards: 4,
+			URL:         "wss://localhost",
+		})
+		if err := mngr.prepare(); err == nil {
+			t.Error("expected an error for a shard id outside of TotalShards")
+		}
+	})
+}
+
+func TestShardManager_ShardForGuildID(t *testing.T) {
+	mngr := newTestShardManager(&ShardConfig{
+		TotalShards: 16,
+		URL:         "wss://localhost",
+	})
+	if _, err := mngr.ShardForGuildID(Snowflake(228846961774559232)); err == nil {
+		t.Error("expected an error before the shards are created")
+	}
+	if err := mngr.prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	guildID := Snowflake(228846961774559232)
+	shard, err := mngr.ShardForGuildID(guildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wants, _ := mngr.Shard(GetShardForGuildID(guildID, 16))
+	if shard != wants {
+		t.Error("guild was routed to the wrong shard")
+	}
+}
+
+func TestShardManager_ConnectReadyTimeout(t *testing.T) {
+	// the gateway accepts the connection, but never says hello
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&gorilla.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err = conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	mngr := newTestShardManager(&ShardConfig{
+		TotalShards:  1,
+		URL:          "ws" + strings.TrimPrefix(srv.URL, "http"),
+		ReadyTimeout: 50 * time.Millisecond,
+	})
+	mngr.wsConf.HTTPClient = srv.Client()
+	mngr.limiter.Update(1000, 1000, time.Hour, 1)
+
+	if err := mngr.Connect(); err != context.DeadlineExceeded {
+		t.Errorf("expected the ready timeout to expire, got %v", err)
+	}
+}
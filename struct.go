@@ -297,9 +297,10 @@ type GatewayBot struct {
 	Gateway
 	Shards            uint `json:"shards"`
 	SessionStartLimit struct {
-		Total      uint `json:"total"`
-		Remaining  uint `json:"remaining"`
-		ResetAfter uint `json:"reset_after"`
+		Total          uint `json:"total"`
+		Remaining      uint `json:"remaining"`
+		ResetAfter     uint `json:"reset_after"` // milliseconds
+		MaxConcurrency uint `json:"max_concurrency"`
 	} `json:"session_start_limit"`
 }
 
@@ -15,6 +15,20 @@ const (
 	StatusOnline = "online"
 	// StatusOffline presence status for offline
 	StatusOffline = "offline"
+	// StatusInvisible presence status for invisible. The user is shown as offline, while still connected
+	StatusInvisible = "invisible"
+)
+
+// activity types, see Activity.Type
+const (
+	// ActivityTypeGame "Playing {name}"
+	ActivityTypeGame = iota
+	// ActivityTypeStreaming "Streaming {name}", requires Activity.URL
+	ActivityTypeStreaming
+	// ActivityTypeListening "Listening to {name}"
+	ActivityTypeListening
+	// ActivityTypeWatching "Watching {name}"
+	ActivityTypeWatching
 )
 
 // flags for the Activity object to signify the type of action taken place
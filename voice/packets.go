@@ -0,0 +1,74 @@
+package voice
+
+import "encoding/json"
+
+// operation codes sent over the voice websocket connection
+// https://discordapp.com/developers/docs/topics/opcodes-and-status-codes#voice-voice-opcodes
+const (
+	opIdentify uint = iota
+	opSelectProtocol
+	opReady
+	opHeartbeat
+	opSessionDescription
+	opSpeaking
+	opHeartbeatAck
+	opResume
+	opHello
+	opResumed
+	_
+	_
+	_
+	opClientDisconnect
+)
+
+// discordPacket is a packet received from the voice websocket
+type discordPacket struct {
+	Op   uint            `json:"op"`
+	Data json.RawMessage `json:"d"`
+}
+
+// clientPacket is a packet sent to the voice websocket
+type clientPacket struct {
+	Op   uint        `json:"op"`
+	Data interface{} `json:"d"`
+}
+
+type helloPacket struct {
+	HeartbeatInterval float64 `json:"heartbeat_interval"`
+}
+
+type identifyPacket struct {
+	ServerID  string `json:"server_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Token     string `json:"token"`
+}
+
+type readyPacket struct {
+	SSRC  uint32   `json:"ssrc"`
+	IP    string   `json:"ip"`
+	Port  int      `json:"port"`
+	Modes []string `json:"modes"`
+}
+
+type selectProtocolData struct {
+	Address string `json:"address"`
+	Port    uint16 `json:"port"`
+	Mode    string `json:"mode"`
+}
+
+type selectProtocolPacket struct {
+	Protocol string              `json:"protocol"`
+	Data     *selectProtocolData `json:"data"`
+}
+
+type sessionDescriptionPacket struct {
+	Mode      string   `json:"mode"`
+	SecretKey [32]byte `json:"secret_key"`
+}
+
+type speakingPacket struct {
+	Speaking bool   `json:"speaking"`
+	Delay    int    `json:"delay"`
+	SSRC     uint32 `json:"ssrc"`
+}
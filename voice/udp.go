@@ -0,0 +1,92 @@
+package voice
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// encryptionMode is the only encryption mode supported by Disgord
+	encryptionMode = "xsalsa20_poly1305"
+
+	rtpHeaderSize   = 12
+	rtpVersion      = 0x80
+	rtpPayloadType  = 0x78
+	nonceSize       = 24
+	discoveryLength = 70
+
+	// frameDuration is the duration of audio in a single Opus frame. Discord expects 20ms frames.
+	frameDuration = 20 * time.Millisecond
+
+	// frameSamples is the number of samples per channel in one frame at 48kHz
+	frameSamples = 960
+)
+
+// silenceFrame is an Opus frame of silence. Discord recommends sending five of these when audio stops to avoid
+// interpolation of the last frames.
+var silenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+// discoverIP performs IP discovery over the UDP connection to find the external address and port Discord
+// should send audio to.
+// https://discordapp.com/developers/docs/topics/voice-connections#ip-discovery
+func discoverIP(conn *net.UDPConn, ssrc uint32, timeout time.Duration) (ip string, port uint16, err error) {
+	packet := make([]byte, discoveryLength)
+	binary.BigEndian.PutUint32(packet, ssrc)
+
+	if _, err = conn.Write(packet); err != nil {
+		return
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	var n int
+	n, err = conn.Read(packet)
+	if err != nil {
+		return
+	}
+	if n != discoveryLength {
+		err = errors.New("unexpected ip discovery response length: " + strconv.Itoa(n))
+		return
+	}
+
+	// the ip is a null terminated string starting after the ssrc, and the port is stored
+	// as a little endian uint16 in the last two bytes
+	end := 4
+	for end < discoveryLength-2 && packet[end] != 0 {
+		end++
+	}
+	ip = string(packet[4:end])
+	port = binary.LittleEndian.Uint16(packet[discoveryLength-2:])
+
+	if ip == "" {
+		err = errors.New("ip discovery response did not contain an ip address")
+	}
+	return
+}
+
+// rtpHeader creates the RTP header for an audio packet
+func rtpHeader(sequence uint16, timestamp, ssrc uint32) (header [rtpHeaderSize]byte) {
+	header[0] = rtpVersion
+	header[1] = rtpPayloadType
+	binary.BigEndian.PutUint16(header[2:], sequence)
+	binary.BigEndian.PutUint32(header[4:], timestamp)
+	binary.BigEndian.PutUint32(header[8:], ssrc)
+	return
+}
+
+// encryptFrame seals an Opus frame using xsalsa20_poly1305, where the nonce is the RTP header padded with zeros.
+// The returned packet is the RTP header followed by the encrypted frame.
+func encryptFrame(header [rtpHeaderSize]byte, frame []byte, key *[32]byte) []byte {
+	var nonce [nonceSize]byte
+	copy(nonce[:], header[:])
+
+	return secretbox.Seal(header[:], frame, &nonce, key)
+}
@@ -0,0 +1,427 @@
+// Package voice implements the Discord voice gateway and the UDP transport used to send audio to a voice channel.
+//
+// A voice connection requires the session ID found in the VoiceStateUpdate event of the bot user, and the token
+// and endpoint found in the VoiceServerUpdate event. Both events are sent by Discord after emitting a
+// UpdateVoiceStateCommand for the voice channel.
+//
+//	conn, err := voice.Connect(&voice.Config{
+//	    GuildID:   guildID,
+//	    UserID:    botID,
+//	    SessionID: voiceStateUpdate.SessionID,
+//	    Token:     voiceServerUpdate.Token,
+//	    Endpoint:  voiceServerUpdate.Endpoint,
+//	})
+//	if err != nil {
+//	    panic(err)
+//	}
+//	defer conn.Close()
+//
+//	for _, frame := range opusFrames {
+//	    conn.Write(frame)
+//	}
+package voice
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/snowflake/v2"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Version is the voice gateway version supported by this package
+const Version = 3
+
+// Config holds the information needed to establish a voice connection
+type Config struct {
+	// GuildID id of the guild the voice channel belongs to
+	GuildID snowflake.Snowflake
+
+	// UserID id of the bot user
+	UserID snowflake.Snowflake
+
+	// SessionID the session id of the bot user, found in the VoiceStateUpdate event
+	SessionID string
+
+	// Token the voice connection token, found in the VoiceServerUpdate event
+	Token string
+
+	// Endpoint the voice server host, found in the VoiceServerUpdate event. A ws:// or wss:// scheme can be
+	// specified to bypass the default wss scheme.
+	Endpoint string
+
+	// HTTPClient custom http client to support the use of proxy
+	HTTPClient *http.Client
+
+	// Timeout is the deadline for each step of the handshake. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// Connection is an established voice connection which can send Opus frames to the voice channel.
+// It implements io.WriteCloser, where every write must be exactly one Opus frame of 20ms.
+type Connection struct {
+	sync.Mutex
+	conf *Config
+
+	ws      *websocket.Conn
+	wsMutex sync.Mutex
+	udp     *net.UDPConn
+
+	ssrc      uint32
+	secretKey [32]byte
+	sequence  uint16
+	timestamp uint32
+	speaking  bool
+	lastFrame time.Time
+
+	heartbeatInterval time.Duration
+	lastHeartbeatAck  time.Time
+
+	shutdown chan interface{}
+	closed   bool
+}
+
+var _ io.WriteCloser = (*Connection)(nil)
+
+// Connect performs the voice handshake: identify, IP discovery and protocol selection. On success the returned
+// connection is ready to send audio.
+func Connect(conf *Config) (conn *Connection, err error) {
+	if conf.SessionID == "" || conf.Token == "" || conf.Endpoint == "" {
+		err = errors.New("session id, token and endpoint must be set to establish a voice connection")
+		return
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = 10 * time.Second
+	}
+	if conf.HTTPClient == nil {
+		conf.HTTPClient = &http.Client{}
+	}
+
+	conn = &Connection{
+		conf:     conf,
+		shutdown: make(chan interface{}),
+	}
+	if err = conn.open(); err != nil {
+		conn.Close()
+		conn = nil
+	}
+	return
+}
+
+// endpointURL converts the endpoint given in the VoiceServerUpdate event into a websocket url
+func endpointURL(endpoint string) string {
+	if !strings.HasPrefix(endpoint, "ws://") && !strings.HasPrefix(endpoint, "wss://") {
+		// the port given by discord is not the one used for secure connections
+		endpoint = "wss://" + strings.TrimSuffix(endpoint, ":80")
+	}
+
+	return strings.TrimSuffix(endpoint, "/") + "/?v=" + strconv.Itoa(Version)
+}
+
+func (c *Connection) open() (err error) {
+	// by default we use gorilla's websocket dialer here, but if the passed http client uses a custom transport
+	// we make sure we open the websocket over the same transport/proxy, in case the user uses this
+	dialer := websocket.DefaultDialer
+	if t, ok := c.conf.HTTPClient.Transport.(*http.Transport); ok {
+		dialer = &websocket.Dialer{
+			HandshakeTimeout: dialer.HandshakeTimeout,
+			Proxy:            t.Proxy,
+			NetDialContext:   t.DialContext,
+			NetDial:          t.Dial,
+		}
+	}
+
+	c.ws, _, err = dialer.Dial(endpointURL(c.conf.Endpoint), nil)
+	if err != nil {
+		return
+	}
+
+	err = c.emit(opIdentify, &identifyPacket{
+		ServerID:  c.conf.GuildID.String(),
+		UserID:    c.conf.UserID.String(),
+		SessionID: c.conf.SessionID,
+		Token:     c.conf.Token,
+	})
+	if err != nil {
+		return
+	}
+
+	// hello and ready can arrive in any order
+	ready := &readyPacket{}
+	for c.heartbeatInterval == 0 || ready.SSRC == 0 {
+		var p *discordPacket
+		if p, err = c.read(); err != nil {
+			return
+		}
+
+		switch p.Op {
+		case opHello:
+			hello := &helloPacket{}
+			if err = httd.Unmarshal(p.Data, hello); err != nil {
+				return
+			}
+			c.heartbeatInterval = time.Duration(hello.HeartbeatInterval * float64(time.Millisecond))
+		case opReady:
+			if err = httd.Unmarshal(p.Data, ready); err != nil {
+				return
+			}
+			if ready.SSRC == 0 {
+				err = errors.New("voice server sent a ready packet without a ssrc")
+				return
+			}
+		}
+	}
+	c.ssrc = ready.SSRC
+	go c.pulsate()
+
+	supported := false
+	for _, mode := range ready.Modes {
+		if mode == encryptionMode {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		err = errors.New("voice server does not support the encryption mode " + encryptionMode)
+		return
+	}
+
+	// establish the udp connection and find our external address
+	var addr *net.UDPAddr
+	addr, err = net.ResolveUDPAddr("udp", ready.IP+":"+strconv.Itoa(ready.Port))
+	if err != nil {
+		return
+	}
+	if c.udp, err = net.DialUDP("udp", nil, addr); err != nil {
+		return
+	}
+
+	var ip string
+	var port uint16
+	if ip, port, err = discoverIP(c.udp, c.ssrc, c.conf.Timeout); err != nil {
+		return
+	}
+
+	err = c.emit(opSelectProtocol, &selectProtocolPacket{
+		Protocol: "udp",
+		Data: &selectProtocolData{
+			Address: ip,
+			Port:    port,
+			Mode:    encryptionMode,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for {
+		var p *discordPacket
+		if p, err = c.read(); err != nil {
+			return
+		}
+		if p.Op != opSessionDescription {
+			c.handle(p)
+			continue
+		}
+
+		session := &sessionDescriptionPacket{}
+		if err = httd.Unmarshal(p.Data, session); err != nil {
+			return
+		}
+		if session.Mode != encryptionMode {
+			err = errors.New("voice server selected an unsupported encryption mode: " + session.Mode)
+			return
+		}
+		c.secretKey = session.SecretKey
+		break
+	}
+
+	go c.receiver()
+	return
+}
+
+// read reads the next packet from the voice websocket, respecting the handshake timeout
+func (c *Connection) read() (p *discordPacket, err error) {
+	if err = c.ws.SetReadDeadline(time.Now().Add(c.conf.Timeout)); err != nil {
+		return
+	}
+	defer c.ws.SetReadDeadline(time.Time{})
+
+	var data []byte
+	if _, data, err = c.ws.ReadMessage(); err != nil {
+		return
+	}
+
+	p = &discordPacket{}
+	err = httd.Unmarshal(data, p)
+	return
+}
+
+// emit sends a packet over the voice websocket
+func (c *Connection) emit(op uint, data interface{}) (err error) {
+	c.wsMutex.Lock()
+	defer c.wsMutex.Unlock()
+
+	var w io.WriteCloser
+	if w, err = c.ws.NextWriter(websocket.TextMessage); err != nil {
+		return
+	}
+	return httd.JSONEncode(w, &clientPacket{Op: op, Data: data})
+}
+
+// handle deals with packets received after the handshake
+func (c *Connection) handle(p *discordPacket) {
+	switch p.Op {
+	case opHeartbeatAck:
+		c.Lock()
+		c.lastHeartbeatAck = time.Now()
+		c.Unlock()
+	case opSpeaking, opClientDisconnect:
+		// audio from other users is not received, so who speaks is of no interest
+	default:
+		logrus.Debugf("voice: unhandled operation: %d", p.Op)
+	}
+}
+
+func (c *Connection) receiver() {
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			select {
+			case <-c.shutdown:
+			default:
+				logrus.Error("voice: websocket connection closed: ", err)
+			}
+			return
+		}
+
+		p := &discordPacket{}
+		if err = httd.Unmarshal(data, p); err != nil {
+			logrus.Error(err)
+			continue
+		}
+		c.handle(p)
+	}
+}
+
+func (c *Connection) pulsate() {
+	ticker := time.NewTicker(c.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		// the nonce is echoed back by discord in the heartbeat ack
+		nonce := time.Now().UnixNano() / int64(time.Millisecond)
+		if err := c.emit(opHeartbeat, nonce); err != nil {
+			logrus.Error("voice: unable to send heartbeat: ", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.shutdown:
+			return
+		}
+	}
+}
+
+// Speaking notifies Discord whether or not audio is being sent. Write calls this automatically before sending
+// the first frame. When audio stops, five frames of silence are sent to avoid unintended Opus interpolation.
+func (c *Connection) Speaking(speaking bool) (err error) {
+	c.Lock()
+	if c.speaking == speaking {
+		c.Unlock()
+		return
+	}
+	c.speaking = speaking
+	c.Unlock()
+
+	if !speaking {
+		for i := 0; i < 5; i++ {
+			if err = c.sendFrame(silenceFrame); err != nil {
+				return
+			}
+		}
+	}
+
+	return c.emit(opSpeaking, &speakingPacket{
+		Speaking: speaking,
+		SSRC:     c.ssrc,
+	})
+}
+
+// Write sends one Opus encoded frame of 20ms audio to the voice channel. Frames are paced such that
+// consecutive writes are sent 20ms apart.
+func (c *Connection) Write(frame []byte) (n int, err error) {
+	if err = c.Speaking(true); err != nil {
+		return
+	}
+	if err = c.sendFrame(frame); err != nil {
+		return
+	}
+
+	n = len(frame)
+	return
+}
+
+func (c *Connection) sendFrame(frame []byte) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closed {
+		err = errors.New("voice connection is closed")
+		return
+	}
+
+	// pace the frames
+	if wait := c.lastFrame.Add(frameDuration).Sub(time.Now()); wait > 0 {
+		<-time.After(wait)
+	}
+
+	header := rtpHeader(c.sequence, c.timestamp, c.ssrc)
+	_, err = c.udp.Write(encryptFrame(header, frame, &c.secretKey))
+
+	c.lastFrame = time.Now()
+	c.sequence++
+	c.timestamp += frameSamples
+	return
+}
+
+// LastHeartbeatAck returns the time the last heartbeat ack was received from the voice server
+func (c *Connection) LastHeartbeatAck() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.lastHeartbeatAck
+}
+
+// Close stops sending audio and closes both the websocket and the UDP connection
+func (c *Connection) Close() (err error) {
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return errors.New("voice connection is already closed")
+	}
+	c.closed = true
+	c.Unlock()
+
+	close(c.shutdown)
+	if c.ws != nil {
+		c.wsMutex.Lock()
+		err = c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		c.wsMutex.Unlock()
+		c.ws.Close()
+	}
+	if c.udp != nil {
+		if e := c.udp.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
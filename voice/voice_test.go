@@ -0,0 +1,328 @@
+package voice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andersfylling/disgord/httd"
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// decryptPacket opens an audio packet the same way Discord does, returning the RTP header and the Opus frame
+func decryptPacket(packet []byte, key *[32]byte) (header [rtpHeaderSize]byte, frame []byte, ok bool) {
+	if len(packet) < rtpHeaderSize {
+		return
+	}
+	copy(header[:], packet)
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], header[:])
+	frame, ok = secretbox.Open(nil, packet[rtpHeaderSize:], &nonce, key)
+	return
+}
+
+type testVoiceServer struct {
+	ws  *httptest.Server
+	udp *net.UDPConn
+	key [32]byte
+
+	identify chan *identifyPacket
+	protocol chan *selectProtocolPacket
+	speaking chan *speakingPacket
+	packets  chan []byte
+}
+
+func newTestVoiceServer(t *testing.T) *testVoiceServer {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testVoiceServer{
+		udp:      udp,
+		identify: make(chan *identifyPacket, 1),
+		protocol: make(chan *selectProtocolPacket, 1),
+		speaking: make(chan *speakingPacket, 10),
+		packets:  make(chan []byte, 100),
+	}
+	for i := range s.key {
+		s.key[i] = byte(i + 1)
+	}
+
+	s.ws = httptest.NewServer(http.HandlerFunc(s.serveWS))
+	go s.serveUDP()
+	return s
+}
+
+func (s *testVoiceServer) endpoint() string {
+	return strings.Replace(s.ws.URL, "http://", "ws://", 1)
+}
+
+func (s *testVoiceServer) Close() {
+	s.ws.Close()
+	s.udp.Close()
+}
+
+func (s *testVoiceServer) serveUDP() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.udp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		if n == discoveryLength {
+			resp := make([]byte, discoveryLength)
+			copy(resp, buf[:4])
+			copy(resp[4:], addr.IP.String())
+			binary.LittleEndian.PutUint16(resp[discoveryLength-2:], uint16(addr.Port))
+			s.udp.WriteToUDP(resp, addr)
+			continue
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+		s.packets <- packet
+	}
+}
+
+func (s *testVoiceServer) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	send := func(op uint, data interface{}) {
+		b, _ := httd.Marshal(&clientPacket{Op: op, Data: data})
+		conn.WriteMessage(websocket.TextMessage, b)
+	}
+	send(opHello, &helloPacket{HeartbeatInterval: 41250})
+
+	addr := s.udp.LocalAddr().(*net.UDPAddr)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		p := &discordPacket{}
+		if err = httd.Unmarshal(data, p); err != nil {
+			return
+		}
+
+		switch p.Op {
+		case opIdentify:
+			identify := &identifyPacket{}
+			httd.Unmarshal(p.Data, identify)
+			s.identify <- identify
+			send(opReady, &readyPacket{
+				SSRC:  1234,
+				IP:    addr.IP.String(),
+				Port:  addr.Port,
+				Modes: []string{"xsalsa20_poly1305_lite", encryptionMode},
+			})
+		case opSelectProtocol:
+			protocol := &selectProtocolPacket{}
+			httd.Unmarshal(p.Data, protocol)
+			s.protocol <- protocol
+			send(opSessionDescription, &sessionDescriptionPacket{
+				Mode:      encryptionMode,
+				SecretKey: s.key,
+			})
+		case opHeartbeat:
+			send(opHeartbeatAck, p.Data)
+		case opSpeaking:
+			speaking := &speakingPacket{}
+			httd.Unmarshal(p.Data, speaking)
+			s.speaking <- speaking
+		}
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	tests := map[string]string{
+		"eu-central123.discord.gg:80": "wss://eu-central123.discord.gg/?v=3",
+		"eu-central123.discord.gg":    "wss://eu-central123.discord.gg/?v=3",
+		"ws://127.0.0.1:4000":         "ws://127.0.0.1:4000/?v=3",
+		"wss://127.0.0.1:4000/":       "wss://127.0.0.1:4000/?v=3",
+	}
+
+	for endpoint, expected := range tests {
+		if got := endpointURL(endpoint); got != expected {
+			t.Errorf("endpoint %s: expected %s, got %s", endpoint, expected, got)
+		}
+	}
+}
+
+func TestSessionDescriptionPacket(t *testing.T) {
+	data := []byte(`{"mode":"xsalsa20_poly1305","secret_key":[1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23,24,25,26,27,28,29,30,31,32]}`)
+
+	p := &sessionDescriptionPacket{}
+	if err := httd.Unmarshal(data, p); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range p.SecretKey {
+		if p.SecretKey[i] != byte(i+1) {
+			t.Fatalf("secret key was not decoded correctly, got %v", p.SecretKey)
+		}
+	}
+}
+
+func TestRTPHeader(t *testing.T) {
+	header := rtpHeader(0x0102, 0x03040506, 0x0708090a)
+	expected := [rtpHeaderSize]byte{0x80, 0x78, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a}
+	if header != expected {
+		t.Errorf("expected header %v, got %v", expected, header)
+	}
+}
+
+func TestEncryptFrame(t *testing.T) {
+	var key [32]byte
+	key[0] = 42
+	header := rtpHeader(1, 960, 1234)
+	frame := []byte{1, 2, 3, 4}
+
+	packet := encryptFrame(header, frame, &key)
+	if !bytes.Equal(packet[:rtpHeaderSize], header[:]) {
+		t.Error("packet does not start with the rtp header")
+	}
+
+	h, decrypted, ok := decryptPacket(packet, &key)
+	if !ok {
+		t.Fatal("unable to decrypt packet")
+	}
+	if h != header {
+		t.Errorf("expected header %v, got %v", header, h)
+	}
+	if !bytes.Equal(decrypted, frame) {
+		t.Errorf("expected frame %v, got %v", frame, decrypted)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	server := newTestVoiceServer(t)
+	defer server.Close()
+
+	conn, err := Connect(&Config{
+		GuildID:   1,
+		UserID:    2,
+		SessionID: "session",
+		Token:     "token",
+		Endpoint:  server.endpoint(),
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	identify := <-server.identify
+	if identify.ServerID != "1" || identify.UserID != "2" || identify.SessionID != "session" || identify.Token != "token" {
+		t.Errorf("unexpected identify packet: %+v", identify)
+	}
+
+	protocol := <-server.protocol
+	if protocol.Protocol != "udp" || protocol.Data.Mode != encryptionMode {
+		t.Errorf("unexpected select protocol packet: %+v", protocol)
+	}
+	if protocol.Data.Address != "127.0.0.1" || int(protocol.Data.Port) != conn.udp.LocalAddr().(*net.UDPAddr).Port {
+		t.Errorf("ip discovery returned the wrong address: %s:%d", protocol.Data.Address, protocol.Data.Port)
+	}
+
+	t.Run("write", func(t *testing.T) {
+		frames := [][]byte{{1}, {2, 2}, {3, 3, 3}}
+		for _, frame := range frames {
+			if _, err := conn.Write(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		select {
+		case speaking := <-server.speaking:
+			if !speaking.Speaking || speaking.SSRC != 1234 {
+				t.Errorf("unexpected speaking packet: %+v", speaking)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("speaking was never sent")
+		}
+
+		for i, frame := range frames {
+			var packet []byte
+			select {
+			case packet = <-server.packets:
+			case <-time.After(time.Second):
+				t.Fatal("audio packet was never received")
+			}
+
+			header, decrypted, ok := decryptPacket(packet, &server.key)
+			if !ok {
+				t.Fatal("unable to decrypt audio packet")
+			}
+			if seq := binary.BigEndian.Uint16(header[2:]); seq != uint16(i) {
+				t.Errorf("expected sequence %d, got %d", i, seq)
+			}
+			if ts := binary.BigEndian.Uint32(header[4:]); ts != uint32(i*frameSamples) {
+				t.Errorf("expected timestamp %d, got %d", i*frameSamples, ts)
+			}
+			if ssrc := binary.BigEndian.Uint32(header[8:]); ssrc != 1234 {
+				t.Errorf("expected ssrc 1234, got %d", ssrc)
+			}
+			if !bytes.Equal(decrypted, frame) {
+				t.Errorf("expected frame %v, got %v", frame, decrypted)
+			}
+		}
+	})
+
+	t.Run("stop speaking", func(t *testing.T) {
+		if err := conn.Speaking(false); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 5; i++ {
+			var packet []byte
+			select {
+			case packet = <-server.packets:
+			case <-time.After(time.Second):
+				t.Fatal("silence frame was never received")
+			}
+
+			_, decrypted, ok := decryptPacket(packet, &server.key)
+			if !ok || !bytes.Equal(decrypted, silenceFrame) {
+				t.Errorf("expected silence frame, got %v", decrypted)
+			}
+		}
+
+		select {
+		case speaking := <-server.speaking:
+			if speaking.Speaking {
+				t.Error("expected speaking to be false")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("speaking was never sent")
+		}
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		deadline := time.Now().Add(time.Second)
+		for conn.LastHeartbeatAck().IsZero() && time.Now().Before(deadline) {
+			<-time.After(10 * time.Millisecond)
+		}
+		if conn.LastHeartbeatAck().IsZero() {
+			t.Error("heartbeat was never acknowledged")
+		}
+	})
+}
+
+func TestConnect_missingCredentials(t *testing.T) {
+	if _, err := Connect(&Config{Endpoint: "localhost"}); err == nil {
+		t.Error("expected an error when session id and token are missing")
+	}
+}
@@ -0,0 +1,146 @@
+package disgord
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/andersfylling/disgord/constant"
+	"github.com/andersfylling/disgord/httd"
+)
+
+// webhookHosts are the domains Discord hands out webhook urls for, including sub domains such as canary and ptb
+var webhookHosts = []string{"discordapp.com", "discord.com"}
+
+// ParseWebhookURL extracts the webhook id and token from a webhook url, such as
+// https://discordapp.com/api/webhooks/{webhook.id}/{webhook.token}. The API version may be part of the path.
+func ParseWebhookURL(webhookURL string) (id Snowflake, token string, err error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return
+	}
+
+	var knownHost bool
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range webhookHosts {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			knownHost = true
+			break
+		}
+	}
+	if !knownHost {
+		err = errors.New("not a discord webhook url, unknown host " + u.Host)
+		return
+	}
+
+	// api[/v{version}]/webhooks/{webhook.id}/{webhook.token}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) > 0 && segments[0] == "api" {
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && strings.HasPrefix(segments[0], "v") {
+		segments = segments[1:]
+	}
+	if len(segments) != 3 || segments[0] != "webhooks" || segments[2] == "" {
+		err = errors.New("not a discord webhook url, expected the path /api/webhooks/{id}/{token}")
+		return
+	}
+
+	raw, err := strconv.ParseUint(segments[1], 10, 64)
+	if err != nil {
+		err = errors.New("not a discord webhook url, invalid webhook id " + segments[1])
+		return
+	}
+	return NewSnowflake(raw), segments[2], nil
+}
+
+// WebhookConfig holds the optional settings of a WebhookClient
+type WebhookConfig struct {
+	HTTPClient *http.Client
+
+	// RetryPolicy decides when requests are retried. Defaults to httd.DefaultRetryPolicy.
+	RetryPolicy *httd.RetryPolicy
+
+	// RateLimiter keeps track of the rate limits of the webhook. Defaults to an in memory rate limiter.
+	RateLimiter httd.RateLimiter
+}
+
+// WebhookClient executes a single webhook. The webhook token is the only credential it needs, so it can be used
+// without a bot. It keeps track of the rate limits of the webhook by itself.
+type WebhookClient struct {
+	id    Snowflake
+	token string
+	req   *httd.Client
+}
+
+// NewWebhookClient creates a client for the webhook with the given id and token. The config may be nil.
+func NewWebhookClient(id Snowflake, token string, conf *WebhookConfig) (*WebhookClient, error) {
+	if id.Empty() {
+		return nil, newErrorMissingSnowflake("webhook id is missing")
+	}
+	if token == "" {
+		return nil, errors.New("webhook token is missing")
+	}
+	if conf == nil {
+		conf = &WebhookConfig{}
+	}
+
+	return &WebhookClient{
+		id:    id,
+		token: token,
+		req: httd.NewClient(&httd.Config{
+			APIVersion:           constant.DiscordVersion,
+			WithoutAuthorization: true,
+			UserAgentSourceURL:   constant.GitHubURL,
+			UserAgentVersion:     constant.Version,
+			HTTPClient:           conf.HTTPClient,
+			RetryPolicy:          conf.RetryPolicy,
+			RateLimiter:          conf.RateLimiter,
+		}),
+	}, nil
+}
+
+// NewWebhookClientFromURL creates a client for the webhook url, see ParseWebhookURL. The config may be nil.
+func NewWebhookClientFromURL(webhookURL string, conf *WebhookConfig) (*WebhookClient, error) {
+	id, token, err := ParseWebhookURL(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewWebhookClient(id, token, conf)
+}
+
+// ID returns the id of the webhook
+func (c *WebhookClient) ID() Snowflake {
+	return c.id
+}
+
+// Webhook fetches the webhook. The user of the webhook is not included.
+func (c *WebhookClient) Webhook() (ret *Webhook, err error) {
+	return GetWebhookWithToken(c.req, c.id, c.token)
+}
+
+// Execute posts a message through the webhook. The webhook id and token of the params are ignored. When wait is
+// true, the created message is returned. See ExecuteWebhook.
+func (c *WebhookClient) Execute(params *ExecuteWebhookParams, wait bool) (ret *Message, err error) {
+	if params == nil {
+		return nil, errors.New("params can not be nil")
+	}
+
+	// the params are not changed, as the caller might reuse them for another webhook
+	p := *params
+	p.WebhookID = c.id
+	p.Token = c.token
+	return ExecuteWebhook(c.req, &p, wait, "")
+}
+
+// EditMessage edits a message previously sent by the webhook. See EditWebhookMessage.
+func (c *WebhookClient) EditMessage(msgID Snowflake, params *EditWebhookMessageParams) (ret *Message, err error) {
+	return EditWebhookMessage(c.req, c.id, c.token, msgID, params)
+}
+
+// DeleteMessage deletes a message previously sent by the webhook. See DeleteWebhookMessage.
+func (c *WebhookClient) DeleteMessage(msgID Snowflake) (err error) {
+	return DeleteWebhookMessage(c.req, c.id, c.token, msgID)
+}
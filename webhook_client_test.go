@@ -0,0 +1,92 @@
+package disgord
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseWebhookURL(t *testing.T) {
+	valid := []string{
+		"https://discordapp.com/api/webhooks/123/abc-DEF_1",
+		"https://discord.com/api/webhooks/123/abc-DEF_1",
+		"https://canary.discordapp.com/api/v6/webhooks/123/abc-DEF_1/",
+	}
+	for _, u := range valid {
+		id, token, err := ParseWebhookURL(u)
+		if err != nil {
+			t.Errorf("%s: %s", u, err)
+			continue
+		}
+		if id != 123 || token != "abc-DEF_1" {
+			t.Errorf("%s: got id %s and token %s", u, id, token)
+		}
+	}
+
+	invalid := []string{
+		"https://example.com/api/webhooks/123/abc",
+		"https://discord.com.example.com/api/webhooks/123/abc",
+		"https://discord.com/api/webhooks/123",
+		"https://discord.com/api/webhooks/abc/def",
+		"https://discord.com/api/channels/123/abc",
+		"https://discord.com/api/webhooks/123/abc/slack",
+	}
+	for _, u := range invalid {
+		if _, _, err := ParseWebhookURL(u); err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+	}
+}
+
+// redirectTransport sends every request to the test server
+type redirectTransport struct {
+	url *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = r.url.Scheme
+	req.URL.Host = r.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWebhookClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("expected no authorization, got %s", auth)
+		}
+		if r.URL.Path != "/api/v6/webhooks/1/token" || r.URL.Query().Get("wait") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"content":"deployed"`) {
+			t.Errorf("unexpected body %s", body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"2","content":"deployed"}`))
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+
+	client, err := NewWebhookClientFromURL("https://discordapp.com/api/webhooks/1/token", &WebhookConfig{
+		HTTPClient: &http.Client{Transport: &redirectTransport{url: srvURL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := &ExecuteWebhookParams{Content: "deployed"}
+	msg, err := client.Execute(params, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 2 {
+		t.Errorf("expected the created message, got %+v", msg)
+	}
+	if !params.WebhookID.Empty() || params.Token != "" {
+		t.Error("expected the params to be left untouched")
+	}
+}
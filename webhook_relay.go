@@ -0,0 +1,367 @@
+package disgord
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Sources of incoming webhooks, see WebhookRelayConfig.Source
+const (
+	// WebhookRelayGitHub accepts GitHub webhooks. The event name is the X-GitHub-Event header, and the signature
+	// is the X-Hub-Signature-256 header, or the X-Hub-Signature header for SHA1 signatures.
+	WebhookRelayGitHub = "github"
+
+	// WebhookRelaySlack accepts Slack event callbacks. The event name is the type of the event, and the signature
+	// is the X-Slack-Signature header of the request signed with the X-Slack-Request-Timestamp. URL verification
+	// requests are answered.
+	WebhookRelaySlack = "slack"
+
+	// WebhookRelayGeneric accepts any JSON payload. The event name is the X-Webhook-Event header, and the
+	// signature is the X-Signature-256 header, formatted as sha256={hex encoded HMAC of the body}.
+	WebhookRelayGeneric = "generic"
+)
+
+const (
+	// webhookRelayMaxBodySize is the default WebhookRelayConfig.MaxBodySize
+	webhookRelayMaxBodySize = 1 << 20
+
+	// slackSignatureMaxAge is how old a signed Slack request may be, to protect against replays
+	slackSignatureMaxAge = 5 * time.Minute
+
+	// embed limits of Discord
+	embedTitleLimit       = 256
+	embedDescriptionLimit = 2048
+)
+
+// WebhookRelayEvent is the data the templates of the relay are executed with. The payload holds the decoded JSON
+// body of the request, such that the templates can refer to fields as {{.Payload.repository.full_name}}.
+type WebhookRelayEvent struct {
+	Source  string
+	Event   string
+	Header  http.Header
+	Payload map[string]interface{}
+}
+
+// WebhookRelayTemplate describes the Discord message an event is rendered into. Every field, except the color,
+// is a text/template executed with a *WebhookRelayEvent. An embed is only added when one of the embed fields
+// renders into text.
+type WebhookRelayTemplate struct {
+	Content string
+
+	Title       string
+	Description string
+	URL         string
+	Author      string
+	Footer      string
+	Color       int
+}
+
+// relayTemplate is a parsed WebhookRelayTemplate
+type relayTemplate struct {
+	content     *template.Template
+	title       *template.Template
+	description *template.Template
+	url         *template.Template
+	author      *template.Template
+	footer      *template.Template
+	color       int
+}
+
+func newRelayTemplate(name string, t *WebhookRelayTemplate) (parsed *relayTemplate, err error) {
+	parse := func(field, text string) *template.Template {
+		if err != nil || text == "" {
+			return nil
+		}
+		var tmpl *template.Template
+		tmpl, err = template.New(name + "." + field).Option("missingkey=zero").Parse(text)
+		return tmpl
+	}
+
+	parsed = &relayTemplate{
+		content:     parse("content", t.Content),
+		title:       parse("title", t.Title),
+		description: parse("description", t.Description),
+		url:         parse("url", t.URL),
+		author:      parse("author", t.Author),
+		footer:      parse("footer", t.Footer),
+		color:       t.Color,
+	}
+	return
+}
+
+// render executes the templates. The embed is nil if none of the embed fields have any text.
+func (t *relayTemplate) render(evt *WebhookRelayEvent) (content string, embed *ChannelEmbed, err error) {
+	execute := func(tmpl *template.Template, limit int) string {
+		if err != nil || tmpl == nil {
+			return ""
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, evt); err != nil {
+			return ""
+		}
+		text := strings.TrimSpace(buf.String())
+		if runes := []rune(text); limit > 0 && len(runes) > limit {
+			text = string(runes[:limit-3]) + "..."
+		}
+		return text
+	}
+
+	content = execute(t.content, 0)
+	e := &ChannelEmbed{
+		Type:        "rich",
+		Title:       execute(t.title, embedTitleLimit),
+		Description: execute(t.description, embedDescriptionLimit),
+		URL:         execute(t.url, 0),
+		Color:       t.color,
+		Timestamp:   time.Now(),
+	}
+	if name := execute(t.author, embedTitleLimit); name != "" {
+		e.Author = &ChannelEmbedAuthor{Name: name}
+	}
+	if text := execute(t.footer, embedDescriptionLimit); text != "" {
+		e.Footer = &ChannelEmbedFooter{Text: text}
+	}
+	if err != nil {
+		return
+	}
+
+	if e.Title != "" || e.Description != "" || e.Author != nil || e.Footer != nil {
+		embed = e
+	}
+	return
+}
+
+// WebhookRelayForwarder sends a rendered message to Discord
+type WebhookRelayForwarder func(ctx context.Context, content string, embed *ChannelEmbed) error
+
+// RelayToWebhook forwards the messages of a relay through a Discord webhook
+func RelayToWebhook(client *WebhookClient) WebhookRelayForwarder {
+	return func(ctx context.Context, content string, embed *ChannelEmbed) (err error) {
+		params := &ExecuteWebhookParams{
+			WebhookID: client.id,
+			Token:     client.token,
+			Content:   content,
+		}
+		if embed != nil {
+			params.Embeds = []*ChannelEmbed{embed}
+		}
+		_, err = ExecuteWebhook(client.req.WithContext(ctx), params, false, "")
+		return
+	}
+}
+
+// RelayToChannel forwards the messages of a relay to a channel, using the bot of the session
+func RelayToChannel(session Session, channelID Snowflake) WebhookRelayForwarder {
+	return func(ctx context.Context, content string, embed *ChannelEmbed) (err error) {
+		_, err = session.WithContext(ctx).CreateChannelMessage(channelID, &CreateChannelMessageParams{
+			Content: content,
+			Embed:   embed,
+		})
+		return
+	}
+}
+
+// WebhookRelayConfig configures a WebhookRelay
+type WebhookRelayConfig struct {
+	// Source is the format of the incoming webhooks: WebhookRelayGitHub, WebhookRelaySlack or
+	// WebhookRelayGeneric
+	Source string
+
+	// Secret is the shared secret the incoming webhooks are signed with. Requests without a valid signature are
+	// refused.
+	Secret string
+
+	// Templates renders the events, by event name. The template of the empty event name is used for events
+	// without a template of their own. Events without any template are accepted, but not forwarded.
+	Templates map[string]*WebhookRelayTemplate
+
+	// Forward sends the rendered messages, see RelayToWebhook and RelayToChannel
+	Forward WebhookRelayForwarder
+
+	// MaxBodySize is the largest request body accepted, in bytes. Defaults to 1MB.
+	MaxBodySize int64
+}
+
+// WebhookRelay is a http.Handler that receives webhooks from GitHub, Slack or other tools, and forwards them to
+// Discord as messages rendered by templates. Events are answered with 204 No Content once forwarded, or when there
+// is no template for them. Requests with an invalid signature are refused with 401 Unauthorized, and a 502 Bad
+// Gateway is returned when Discord does not accept the message.
+type WebhookRelay struct {
+	conf      WebhookRelayConfig
+	templates map[string]*relayTemplate
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewWebhookRelay parses the templates of the config and creates the relay
+func NewWebhookRelay(conf *WebhookRelayConfig) (*WebhookRelay, error) {
+	switch conf.Source {
+	case WebhookRelayGitHub, WebhookRelaySlack, WebhookRelayGeneric:
+	default:
+		return nil, errors.New("unknown webhook relay source " + conf.Source)
+	}
+	if conf.Secret == "" {
+		return nil, errors.New("a secret is required to verify the incoming webhooks")
+	}
+	if conf.Forward == nil {
+		return nil, errors.New("a forwarder is required to send the messages to Discord")
+	}
+
+	relay := &WebhookRelay{
+		conf:      *conf,
+		templates: make(map[string]*relayTemplate, len(conf.Templates)),
+		now:       time.Now,
+	}
+	if relay.conf.MaxBodySize <= 0 {
+		relay.conf.MaxBodySize = webhookRelayMaxBodySize
+	}
+	for name, t := range conf.Templates {
+		parsed, err := newRelayTemplate(name, t)
+		if err != nil {
+			return nil, err
+		}
+		relay.templates[name] = parsed
+	}
+	return relay, nil
+}
+
+func (r *WebhookRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests are accepted", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, r.conf.MaxBodySize))
+	if err != nil {
+		http.Error(w, "the body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err = r.verify(req.Header, body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	evt, err := r.decode(req.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.conf.Source == WebhookRelaySlack && evt.Event == "url_verification" {
+		challenge, _ := evt.Payload["challenge"].(string)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(challenge))
+		return
+	}
+
+	t, exists := r.templates[evt.Event]
+	if !exists && !(r.conf.Source == WebhookRelayGitHub && evt.Event == "ping") {
+		// GitHub pings new webhooks, which is only forwarded if there is a template for it
+		t, exists = r.templates[""]
+	}
+	if !exists {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	content, embed, err := t.render(evt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if content == "" && embed == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err = r.conf.Forward(req.Context(), content, embed); err != nil {
+		http.Error(w, "unable to forward the event: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify checks the HMAC signature of the body
+func (r *WebhookRelay) verify(header http.Header, body []byte) error {
+	var newHash func() hash.Hash
+	var signature, signed string
+	switch r.conf.Source {
+	case WebhookRelayGitHub:
+		if s := header.Get("X-Hub-Signature-256"); s != "" {
+			newHash, signature = sha256.New, strings.TrimPrefix(s, "sha256=")
+		} else {
+			newHash, signature = sha1.New, strings.TrimPrefix(header.Get("X-Hub-Signature"), "sha1=")
+		}
+		signed = string(body)
+	case WebhookRelaySlack:
+		timestamp := header.Get("X-Slack-Request-Timestamp")
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return errors.New("missing or invalid request timestamp")
+		}
+		if age := r.now().Sub(time.Unix(unix, 0)); age > slackSignatureMaxAge || age < -slackSignatureMaxAge {
+			return errors.New("the request timestamp is too old")
+		}
+		newHash, signature = sha256.New, strings.TrimPrefix(header.Get("X-Slack-Signature"), "v0=")
+		signed = "v0:" + timestamp + ":" + string(body)
+	case WebhookRelayGeneric:
+		newHash, signature = sha256.New, strings.TrimPrefix(header.Get("X-Signature-256"), "sha256=")
+		signed = string(body)
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil || signature == "" {
+		return errors.New("missing or malformed signature")
+	}
+	mac := hmac.New(newHash, []byte(r.conf.Secret))
+	mac.Write([]byte(signed))
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// decode parses the body, and finds the event name. Form encoded bodies must hold the JSON as the payload field.
+func (r *WebhookRelay) decode(header http.Header, body []byte) (evt *WebhookRelayEvent, err error) {
+	if strings.HasPrefix(header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		var form url.Values
+		if form, err = url.ParseQuery(string(body)); err != nil {
+			return nil, errors.New("malformed form body")
+		}
+		body = []byte(form.Get("payload"))
+	}
+
+	evt = &WebhookRelayEvent{
+		Source: r.conf.Source,
+		Header: header,
+	}
+	if err = unmarshal(body, &evt.Payload); err != nil {
+		return nil, errors.New("the body is not a JSON object")
+	}
+
+	switch r.conf.Source {
+	case WebhookRelayGitHub:
+		evt.Event = header.Get("X-GitHub-Event")
+	case WebhookRelaySlack:
+		evt.Event, _ = evt.Payload["type"].(string)
+		if inner, ok := evt.Payload["event"].(map[string]interface{}); ok {
+			evt.Event, _ = inner["type"].(string)
+		}
+	case WebhookRelayGeneric:
+		evt.Event = header.Get("X-Webhook-Event")
+	}
+	return evt, nil
+}
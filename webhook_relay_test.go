@@ -0,0 +1,137 @@
+package disgord
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type relayedMessage struct {
+	content string
+	embed   *ChannelEmbed
+}
+
+func newTestWebhookRelay(t *testing.T, source string, templates map[string]*WebhookRelayTemplate, forwarded chan<- *relayedMessage) *WebhookRelay {
+	relay, err := NewWebhookRelay(&WebhookRelayConfig{
+		Source:    source,
+		Secret:    "secret",
+		Templates: templates,
+		Forward: func(ctx context.Context, content string, embed *ChannelEmbed) error {
+			forwarded <- &relayedMessage{content: content, embed: embed}
+			if strings.HasSuffix(content, "fail") {
+				return errors.New("discord is down")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return relay
+}
+
+func TestWebhookRelay_GitHub(t *testing.T) {
+	forwarded := make(chan *relayedMessage, 1)
+	relay := newTestWebhookRelay(t, WebhookRelayGitHub, map[string]*WebhookRelayTemplate{
+		"push": {
+			Title:       "{{.Payload.repository.full_name}}: {{len .Payload.commits}} new commit(s)",
+			Description: "{{range .Payload.commits}}{{.message}}\n{{end}}",
+			URL:         "{{.Payload.compare}}",
+		},
+		"": {
+			Content: "{{.Event}} {{.Payload.text}}",
+		},
+	}, forwarded)
+
+	send := func(evt, body, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/github", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", evt)
+		req.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+		rec := httptest.NewRecorder()
+		relay.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	body := `{"repository":{"full_name":"andersfylling/disgord"},"compare":"https://github.com/x","commits":[{"message":"fix"},{"message":"feat"}]}`
+	if code := send("push", body, sign("secret", body)); code != http.StatusNoContent {
+		t.Fatalf("expected the push to be relayed, got %d", code)
+	}
+	msg := <-forwarded
+	if msg.embed == nil || msg.embed.Title != "andersfylling/disgord: 2 new commit(s)" || msg.embed.Description != "fix\nfeat" {
+		t.Errorf("unexpected embed %+v", msg.embed)
+	}
+	if msg.embed.URL != "https://github.com/x" || msg.content != "" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	if code := send("push", body, sign("wrong", body)); code != http.StatusUnauthorized {
+		t.Errorf("expected an invalid signature to be refused, got %d", code)
+	}
+	if code := send("ping", `{"zen":"hi"}`, sign("secret", `{"zen":"hi"}`)); code != http.StatusNoContent {
+		t.Errorf("expected the ping to be accepted, got %d", code)
+	}
+	if code := send("issues", `{"text":"fail"}`, sign("secret", `{"text":"fail"}`)); code != http.StatusBadGateway {
+		t.Errorf("expected a failed forward to be reported, got %d", code)
+	}
+	if msg = <-forwarded; msg.content != "issues fail" {
+		t.Errorf("expected the fallback template, got %+v", msg)
+	}
+	select {
+	case msg = <-forwarded:
+		t.Errorf("expected the ping not to be forwarded, got %+v", msg)
+	default:
+	}
+}
+
+func TestWebhookRelay_Slack(t *testing.T) {
+	forwarded := make(chan *relayedMessage, 1)
+	relay := newTestWebhookRelay(t, WebhookRelaySlack, map[string]*WebhookRelayTemplate{
+		"message": {
+			Content: "{{.Payload.event.text}}",
+		},
+	}, forwarded)
+	now := time.Unix(1600000000, 0)
+	relay.now = func() time.Time {
+		return now
+	}
+
+	send := func(body string, timestamp time.Time) *httptest.ResponseRecorder {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/slack", strings.NewReader(body))
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", "v0="+sign("secret", "v0:"+ts+":"+body))
+		rec := httptest.NewRecorder()
+		relay.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(`{"type":"url_verification","challenge":"abc"}`, now)
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc" {
+		t.Errorf("expected the challenge to be answered, got %d %s", rec.Code, rec.Body)
+	}
+
+	body := `{"type":"event_callback","event":{"type":"message","text":"deployed"}}`
+	if rec = send(body, now.Add(-time.Hour)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an old request to be refused, got %d", rec.Code)
+	}
+	if rec = send(body, now); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the event to be relayed, got %d", rec.Code)
+	}
+	if msg := <-forwarded; msg.content != "deployed" || msg.embed != nil {
+		t.Errorf("unexpected message %+v", msg)
+	}
+}
@@ -1,8 +1,10 @@
 package websocket
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/andersfylling/disgord/constant"
 	"github.com/andersfylling/disgord/httd"
 	"github.com/andersfylling/disgord/websocket/event"
 	"github.com/andersfylling/disgord/websocket/opcode"
@@ -15,14 +17,18 @@ import (
 	"time"
 )
 
-const (
-	maxReconnectTries = 5
-)
-
 // NewManager creates a new socket client manager for handling behavior and Discord events. Note that this
 // function initiates a go routine.
 func NewClient(config *Config) (client *Client, err error) {
-	ws, err := newConn(config.HTTPClient)
+	switch config.Encoding {
+	case "":
+		config.Encoding = constant.JSONEncoding
+	case constant.JSONEncoding, constant.ETFEncoding:
+	default:
+		return nil, errors.New("unsupported encoding: " + config.Encoding)
+	}
+
+	ws, err := newConn(config.HTTPClient, config.Compression, config.Encoding)
 	if err != nil {
 		return nil, err
 	}
@@ -30,14 +36,28 @@ func NewClient(config *Config) (client *Client, err error) {
 	client = &Client{
 		conf:              config,
 		shutdown:          make(chan interface{}),
-		restart:           make(chan interface{}),
 		eventChan:         make(chan *Event),
 		receiveChan:       make(chan *discordPacket),
 		emitChan:          make(chan *clientPacket),
 		conn:              ws,
 		timeoutMultiplier: 1,
-		disconnected:      true,
+		state:             StateDisconnected,
+	}
+
+	session := config.Session
+	if session == nil && config.SessionStore != nil {
+		if session, err = config.SessionStore.Load(config.ShardID, shardCount(config.ShardCount)); err != nil {
+			// identify instead
+			logrus.Error(err)
+			session, err = nil, nil
+		}
 	}
+	if session != nil {
+		if err = client.restore(session); err != nil {
+			return nil, err
+		}
+	}
+
 	client.Start()
 
 	return
@@ -64,9 +84,14 @@ type Config struct {
 	// a valid socket endpoint from Discord
 	Endpoint string
 
-	// Encoding make sure we support the correct encoding
+	// Encoding make sure we support the correct encoding. Either constant.JSONEncoding (default) or
+	// constant.ETFEncoding.
 	Encoding string
 
+	// Compression is the transport compression of the connection. Set it to CompressionZlibStream to reduce the
+	// bandwidth used by the gateway. Defaults to no transport compression.
+	Compression string
+
 	// Version make sure we support the correct Discord version
 	Version int
 
@@ -76,15 +101,41 @@ type Config struct {
 	GuildLargeThreshold uint
 	ShardID             uint
 	ShardCount          uint
+
+	// Session is resumed on the first connect, instead of identifying. See SessionSnapshot.
+	Session *SessionSnapshot
+
+	// SessionStore persists the session, such that a new process can resume it. If Session is nil, the session
+	// is loaded from the store when the client is created.
+	SessionStore SessionStore
+
+	// IdentifyLimiter queues the identify packets. Defaults to the limiter shared by every client in the process
+	// using the same token. See IdentifyLimiterFor.
+	IdentifyLimiter *IdentifyLimiter
+
+	// ReconnectPolicy decides how long to wait between reconnect attempts. Defaults to DefaultReconnectPolicy,
+	// which never gives up.
+	ReconnectPolicy *ReconnectPolicy
+
+	// Presence is sent in the identify packet, such that the client comes online with the given presence.
+	Presence interface{}
+
+	// RejectRateLimitedCommands makes Emit return a *ErrorCommandBudget when the command budget of the
+	// connection is used up, instead of waiting until the command can be sent. See CommandBudget.
+	RejectRateLimitedCommands bool
 }
 
 type Client struct {
 	sync.RWMutex
-	conf         *Config
-	shutdown     chan interface{}
-	restart      chan interface{}
-	lastRestart  int64 //unix
-	restartMutex sync.Mutex
+	conf     *Config
+	shutdown chan interface{}
+	state    ConnectionState
+
+	// stateChanged is closed and replaced on every state change
+	stateChanged chan struct{}
+
+	// fatalErr is set when the client can not connect again, such as when Discord closed the connection for good
+	fatalErr error
 
 	eventChan     chan *Event
 	trackedEvents []string
@@ -98,13 +149,23 @@ type Client struct {
 	trace          []string
 	sequenceNumber uint
 
+	// presence is the last status update, which is re-applied when identifying again. presenceOutdated is set
+	// when it could not be sent to Discord yet.
+	presence         interface{}
+	presenceOutdated bool
+
+	// commands counts the commands sent over the current connection
+	commands commandLimiter
+
 	pulsating  uint8
 	pulseMutex sync.Mutex
 
-	receiveChan  chan *discordPacket
-	emitChan     chan *clientPacket
-	conn         Conn
-	disconnected bool
+	receiveChan chan *discordPacket
+	emitChan    chan *clientPacket
+	conn        Conn
+
+	// connClosed is closed when the current connection is closed on purpose
+	connClosed chan struct{}
 
 	// identify timeout on invalid session
 	timeoutMultiplier int
@@ -112,60 +173,94 @@ type Client struct {
 
 // Connect establishes a socket connection with the Discord API
 func (m *Client) Connect() (err error) {
+	if err = m.beginConnect(StateDisconnected); err != nil {
+		return
+	}
+
+	if err = m.open(); err != nil {
+		m.transition(StateDisconnected, err)
+	}
+	return
+}
+
+// beginConnect moves the client into the Connecting state, given that it is in the expected state
+func (m *Client) beginConnect(from ConnectionState) (err error) {
 	m.Lock()
-	defer m.Unlock()
+	state := m.state
+	var change *StateChange
+	if state == from {
+		m.fatalErr = nil
+		change = m.setState(StateConnecting, nil)
+	}
+	m.Unlock()
 
-	// m.conn.Disconnected can always tell us if we are disconnected, but it cannot with
-	// certainty say if we are connected
-	if !m.disconnected {
-		err = errors.New("cannot connect while a connection already exist")
-		return
+	switch {
+	case state == StateClosed:
+		err = errors.New("cannot connect after the client is shut down")
+	case state != from:
+		err = errors.New("cannot connect while " + state.String())
 	}
+	m.notifyState(change)
+	return
+}
+
+// open establishes the websocket connection and starts reading from it
+func (m *Client) open() (err error) {
+	m.Lock()
+	defer m.Unlock()
 
 	if m.conf.Endpoint == "" {
-		m.conf.Endpoint, err = getGatewayRoute(m.conf.HTTPClient, m.conf.Version)
+		m.conf.Endpoint, err = getGatewayRoute(m.conf.HTTPClient, m.conf.Version, m.conf.Encoding)
 		if err != nil {
 			return
 		}
 	}
 
-	// ready the error handler
-	defer func(err error) error {
-		if err != nil {
-			if m.conn != nil {
-				m.conn.Close()
-			}
-			return err
-		}
-		return nil
-	}(err)
+	var endpoint string
+	endpoint, err = gatewayURL(m.conf.Endpoint, m.conf.Compression)
+	if err != nil {
+		return
+	}
 
 	// establish ws connection
-	err = m.conn.Open(m.conf.Endpoint, nil)
+	err = m.conn.Open(endpoint, nil)
 	if err != nil {
 		return
 	}
 
 	// we can now interact with Discord
-	m.disconnected = false
-	go m.receiver()
+	m.commands.reset()
+	m.connClosed = make(chan struct{})
+	go m.receiver(m.connClosed)
 	go m.emitter()
 	return
 }
 
-// Disconnect disconnects the socket connection
+// Disconnect disconnects the socket connection. The client does not reconnect until Connect is called.
 func (m *Client) Disconnect() (err error) {
 	m.Lock()
-	defer m.Unlock()
+	change := m.setState(StateDisconnected, nil)
+	err = m.disconnect()
+	m.Unlock()
+
+	m.notifyState(change)
+	return
+}
+
+// disconnect closes the current connection. Must be called with the lock held.
+func (m *Client) disconnect() (err error) {
+	if m.connClosed != nil {
+		close(m.connClosed)
+		m.connClosed = nil
+	}
+
 	if m.conn.Disconnected() {
-		m.disconnected = true
 		err = errors.New("already disconnected")
 		return
 	}
 
 	// use the emitter to dispatch the close message
 	m.Emit(event.Close, nil)
-	m.disconnected = true
 
 	// close connection
 	<-time.After(time.Second * 1 * time.Duration(m.timeoutMultiplier))
@@ -175,7 +270,8 @@ func (m *Client) Disconnect() (err error) {
 	return
 }
 
-// Emit emits a command, if supported, and its data to the Discord Socket API
+// Emit emits a command, if supported, and its data to the Discord Socket API. Commands wait until they fit in
+// the command budget of the connection, see CommandBudget and Config.RejectRateLimitedCommands.
 func (m *Client) Emit(command string, data interface{}) (err error) {
 	var op uint
 	switch command {
@@ -195,11 +291,23 @@ func (m *Client) Emit(command string, data interface{}) (err error) {
 		op = opcode.VoiceStateUpdate
 	case event.StatusUpdate:
 		op = opcode.StatusUpdate
+		m.Lock()
+		m.presence = data
+		m.presenceOutdated = m.state != StateReady
+		outdated := m.presenceOutdated
+		m.Unlock()
+		if outdated {
+			// sent once the session is ready
+			return
+		}
 	default:
 		err = errors.New("unsupported command: " + command)
 		return
 	}
 
+	if err = m.reserveCommand(command, op); err != nil {
+		return
+	}
 	m.emitChan <- &clientPacket{
 		Op:   op,
 		Data: data,
@@ -228,8 +336,17 @@ func (m *Client) emitter() {
 			m.conn.Close()
 			return
 		}
+		if msg.Op == opcode.Detach {
+			m.conn.CloseResumable()
+			return
+		}
 
-		err := m.conn.WriteJSON(msg)
+		var err error
+		if m.conf.Encoding == constant.ETFEncoding {
+			err = m.conn.WriteETF(msg)
+		} else {
+			err = m.conn.WriteJSON(msg)
+		}
 		if err != nil {
 			// TODO-logging
 			fmt.Printf("could not send data to discord: %+v\n", msg)
@@ -237,11 +354,17 @@ func (m *Client) emitter() {
 	}
 }
 
-func (m *Client) receiver() {
+func (m *Client) receiver(closed <-chan struct{}) {
 	for {
 		packet, err := m.conn.Read()
 		if err != nil {
 			logrus.Debug("closing readPump")
+			select {
+			case <-closed:
+			case <-m.shutdown:
+			default:
+				go m.connectionLost(err)
+			}
 			return
 		}
 
@@ -249,7 +372,11 @@ func (m *Client) receiver() {
 
 		// parse to gateway payload object
 		evt := &discordPacket{}
-		err = evt.UnmarshalJSON(packet)
+		if m.conf.Encoding == constant.ETFEncoding {
+			err = evt.UnmarshalETF(packet)
+		} else {
+			err = evt.UnmarshalJSON(packet)
+		}
 		if err != nil {
 			logrus.Error(err)
 			continue
@@ -308,6 +435,11 @@ func (m *Client) RemoveEvent(event string) {
 	return
 }
 
+// TracksEvent checks if the event type is registered, see RegisterEvent.
+func (m *Client) TracksEvent(event string) bool {
+	return m.eventOfInterest(event)
+}
+
 func (m *Client) EventChan() <-chan *Event {
 	return m.eventChan
 }
@@ -316,59 +448,73 @@ func (m *Client) Start() {
 	go m.operationHandlers()
 }
 
+// Shutdown disconnects the client, which moves into the Closed state and can not be connected again
 func (m *Client) Shutdown() (err error) {
+	if m.State() == StateClosed {
+		return errors.New("already shut down")
+	}
+
 	m.Disconnect()
+	m.transition(StateClosed, nil)
 	close(m.shutdown)
 	return
 }
 
-func (m *Client) lockRestart() bool {
-	m.restartMutex.Lock()
-	defer m.restartMutex.Unlock()
-
-	now := time.Now().UnixNano()
-	locked := (now - m.lastRestart) > (time.Second.Nanoseconds() / 2)
-
-	if locked {
-		m.lastRestart = now
-	}
-
-	return locked
-}
-
-func (m *Client) reconnect() (err error) {
-	// can we lock the restart process?
-	// if we cannot lock it, exit
-	if !m.lockRestart() {
+// reconnect replaces the current connection, and keeps trying to connect as given by the reconnect policy.
+// Nothing is done if the client is already reconnecting, or was disconnected on purpose.
+func (m *Client) reconnect(reason error) {
+	m.Lock()
+	switch m.state {
+	case StateDisconnected, StateReconnecting, StateClosed:
+		m.Unlock()
 		return
 	}
+	change := m.setState(StateReconnecting, reason)
+	_ = m.disconnect()
+	m.Unlock()
 
-	m.restart <- 1
-	_ = m.Disconnect()
-
-	for try := 0; try <= maxReconnectTries; try++ {
-		logrus.Debugf("Reconnect attempt #%d\n", try)
-		err = m.Connect()
-		if err == nil {
-			logrus.Info("successfully reconnected")
-			break
+	policy := m.reconnectPolicy()
+	for attempt := uint(1); ; attempt++ {
+		delay := policy.Delay(attempt)
+		if attempt > 1 {
+			m.Lock()
+			if m.state != StateConnecting {
+				// disconnected or shut down during the last attempt
+				m.Unlock()
+				return
+			}
+			change = m.setState(StateReconnecting, reason)
+			m.Unlock()
 		}
-		if try == maxReconnectTries {
-			err = errors.New("Too many reconnect attempts")
-			return err
+		if change != nil {
+			change.Attempt = attempt
+			change.Delay = delay
 		}
+		m.notifyState(change)
 
-		// wait N seconds
-		logrus.Info("reconnect failed, trying again in N seconds; N = " + strconv.Itoa((try+3)*2))
-		logrus.Info(err)
+		logrus.Debugf("reconnect attempt #%d in %s", attempt, delay)
 		select {
-		case <-time.After(time.Duration((try+3)*2) * time.Second):
+		case <-time.After(delay):
 		case <-m.shutdown:
 			return
 		}
-	}
 
-	return
+		// the client was disconnected or shut down while waiting
+		if err := m.beginConnect(StateReconnecting); err != nil {
+			return
+		}
+
+		if reason = m.open(); reason == nil {
+			logrus.Info("successfully reconnected")
+			return
+		}
+		logrus.Info("reconnect failed: ", reason)
+
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			m.transition(StateDisconnected, errors.New("gave up reconnecting after "+strconv.Itoa(int(attempt))+" attempts: "+reason.Error()))
+			return
+		}
+	}
 }
 
 func (m *Client) eventHandler(p *discordPacket) {
@@ -377,13 +523,19 @@ func (m *Client) eventHandler(p *discordPacket) {
 
 	// increment the sequence number for each event to make sure everything is synced with discord
 	m.Lock()
+	if m.state == StateClosed {
+		// the session was handed off, and the next process continues from the last processed event
+		m.Unlock()
+		return
+	}
 	m.sequenceNumber++
 
 	// validate the sequence numbers
 	if p.SequenceNumber != m.sequenceNumber {
 		m.sequenceNumber--
+		err := errors.New("expected sequence number " + strconv.Itoa(int(m.sequenceNumber+1)) + ", got " + strconv.Itoa(int(p.SequenceNumber)))
 		m.Unlock()
-		go m.reconnect()
+		go m.reconnect(err)
 		return
 	}
 	m.Unlock()
@@ -400,9 +552,13 @@ func (m *Client) eventHandler(p *discordPacket) {
 		m.sessionID = ready.SessionID
 		m.trace = ready.Trace
 		m.Unlock()
-	} else if p.EventName == event.Resume {
-		// eh? debugging.
-		// TODO
+		m.saveSession()
+		m.transition(StateReady, nil)
+		m.sendOutdatedPresence()
+	} else if p.EventName == event.Resumed {
+		m.saveSession()
+		m.transition(StateReady, nil)
+		m.sendOutdatedPresence()
 	} else if p.Op == opcode.DiscordEvent && !m.eventOfInterest(p.EventName) {
 		return
 	}
@@ -439,7 +595,6 @@ func (m *Client) operationHandlers() {
 				logrus.Debug("operationChan is dead..")
 				return
 			}
-		// case <-m.restart:
 		case <-m.shutdown:
 			logrus.Debug("exiting operation handler")
 			return
@@ -450,17 +605,34 @@ func (m *Client) operationHandlers() {
 		case opcode.DiscordEvent:
 			m.eventHandler(p)
 		case opcode.Reconnect:
-			go m.reconnect()
+			go m.reconnect(errors.New("discord requested a reconnect"))
 		case opcode.InvalidSession:
-			// invalid session. Must respond with a identify packet
+			// invalid session. Must respond with a identify packet, unless Discord says it can be resumed
+			var resumable bool
+			if err := httd.Unmarshal(p.Data, &resumable); err != nil {
+				logrus.Debug(err)
+			}
+			if !resumable {
+				m.Lock()
+				m.sessionID = ""
+				m.sequenceNumber = 0
+				m.Unlock()
+				m.saveSession()
+				m.transition(StateIdentifying, nil)
+			} else {
+				m.transition(StateResuming, nil)
+			}
+
 			go func() {
 				rand.Seed(time.Now().UnixNano())
 				delay := rand.Intn(4) + 1
 				delay *= m.timeoutMultiplier
 				randomDelay := time.Second * time.Duration(delay)
 				<-time.After(randomDelay)
-				err := sendIdentityPacket(m)
-				if err != nil {
+
+				if !resumable {
+					m.identify()
+				} else if err := m.sendResumePacket(); err != nil {
 					logrus.Error(err)
 				}
 			}()
@@ -497,20 +669,23 @@ func (m *Client) sendHelloPacket() {
 
 	// if this is a new connection we can drop the resume packet
 	if m.sessionID == "" && m.sequenceNumber == 0 {
-		err := sendIdentityPacket(m)
-		if err != nil {
-			logrus.Error(err)
-		}
+		m.transition(StateIdentifying, nil)
+		go m.identify()
 		return
 	}
 
+	m.transition(StateResuming, nil)
+	m.sendResumePacket()
+}
+
+func (m *Client) sendResumePacket() error {
 	m.RLock()
 	token := m.conf.Token
 	session := m.sessionID
 	sequence := m.sequenceNumber
 	m.RUnlock()
 
-	m.Emit(event.Resume, struct {
+	return m.Emit(event.Resume, struct {
 		Token      string `json:"token"`
 		SessionID  string `json:"session_id"`
 		SequenceNr *uint  `json:"seq"`
@@ -548,6 +723,7 @@ func (m *Client) pulsate() {
 
 	m.RLock()
 	ticker := time.NewTicker(time.Millisecond * time.Duration(m.heartbeatInterval))
+	closed := m.connClosed
 	m.RUnlock()
 	defer ticker.Stop()
 
@@ -560,6 +736,7 @@ func (m *Client) pulsate() {
 		m.RUnlock()
 
 		m.Emit(event.Heartbeat, snr)
+		m.saveSession()
 
 		stopChan := make(chan interface{})
 
@@ -577,7 +754,7 @@ func (m *Client) pulsate() {
 
 			if !receivedHeartbeatAck {
 				logrus.Debug("heartbeat ACK was not received")
-				m.reconnect()
+				m.reconnect(errors.New("heartbeat ACK was not received"))
 			} else {
 				// update "latency"
 				m.heartbeatLatency = m.lastHeartbeatAck.Sub(sent)
@@ -588,7 +765,7 @@ func (m *Client) pulsate() {
 		case <-ticker.C:
 			continue
 		case <-m.shutdown:
-		case <-m.restart:
+		case <-closed:
 		}
 
 		logrus.Debug("Stopping pulse")
@@ -597,6 +774,38 @@ func (m *Client) pulsate() {
 	}
 }
 
+func (m *Client) identifyLimiter() *IdentifyLimiter {
+	if m.conf.IdentifyLimiter != nil {
+		return m.conf.IdentifyLimiter
+	}
+	return IdentifyLimiterFor(m.conf.Token)
+}
+
+// identify sends the identify packet once the identify limiter allows it
+func (m *Client) identify() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := m.identifyLimiter().Wait(ctx, m.conf.ShardID); err != nil {
+		logrus.Error(err)
+		if _, refused := err.(*ErrorIdentifyBudget); refused {
+			// there are no identifies left until the session start limit resets
+			m.fail(err)
+		}
+		return
+	}
+	if err := sendIdentityPacket(m); err != nil {
+		logrus.Error(err)
+	}
+}
+
 func sendIdentityPacket(m *Client) (err error) {
 	// https://discordapp.com/developers/docs/topics/gateway#identify
 	identityPayload := struct {
@@ -614,14 +823,16 @@ func sendIdentityPacket(m *Client) (err error) {
 			Device  string `json:"$device"`
 		}{runtime.GOOS, m.conf.Browser, m.conf.Device},
 		LargeThreshold: m.conf.GuildLargeThreshold,
-		// Presence: struct {
-		// 	Since  *uint       `json:"since"`
-		// 	Game   interface{} `json:"game"`
-		// 	Status string      `json:"status"`
-		// 	AFK    bool        `json:"afk"`
-		// }{Status: "online"},
+		Presence:       m.conf.Presence,
 	}
 
+	m.Lock()
+	if m.presence != nil {
+		identityPayload.Presence = m.presence
+	}
+	m.presenceOutdated = false
+	m.Unlock()
+
 	if m.conf.ShardCount > 1 {
 		identityPayload.Shard = &[2]uint{m.conf.ShardID, m.conf.ShardCount}
 	}
@@ -629,3 +840,19 @@ func sendIdentityPacket(m *Client) (err error) {
 	err = m.Emit(event.Identify, &identityPayload)
 	return
 }
+
+// sendOutdatedPresence sends the status update that was emitted while the session was not ready
+func (m *Client) sendOutdatedPresence() {
+	m.Lock()
+	presence, outdated := m.presence, m.presenceOutdated
+	m.presenceOutdated = false
+	m.Unlock()
+
+	if outdated {
+		m.commands.reserve(time.Now(), true)
+		m.emitChan <- &clientPacket{
+			Op:   opcode.StatusUpdate,
+			Data: presence,
+		}
+	}
+}
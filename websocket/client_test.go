@@ -1,12 +1,15 @@
 package websocket
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/andersfylling/disgord/constant"
+	"github.com/andersfylling/disgord/websocket/event"
 	"github.com/andersfylling/disgord/websocket/opcode"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -18,14 +21,20 @@ type testWS struct {
 	writing      chan interface{}
 	reading      chan []byte
 	disconnected bool
+	openErr      error
+	readErr      error
+	resumable    bool
 	sync.Mutex
 }
 
 func (g *testWS) Open(endpoint string, requestHeader http.Header) (err error) {
 	g.opening <- 1
 	g.Lock()
+	defer g.Unlock()
+	if g.openErr != nil {
+		return g.openErr
+	}
 	g.disconnected = false
-	g.Unlock()
 	return
 }
 
@@ -34,6 +43,11 @@ func (g *testWS) WriteJSON(v interface{}) (err error) {
 	return
 }
 
+func (g *testWS) WriteETF(v interface{}) (err error) {
+	g.writing <- v
+	return
+}
+
 func (g *testWS) Close() (err error) {
 	g.closing <- 1
 	g.Lock()
@@ -42,15 +56,29 @@ func (g *testWS) Close() (err error) {
 	return
 }
 
+func (g *testWS) CloseResumable() (err error) {
+	g.Lock()
+	g.resumable = true
+	g.Unlock()
+	return g.Close()
+}
+
 func (g *testWS) Read() (packet []byte, err error) {
 	packet = <-g.reading
 	if packet == nil {
-		err = errors.New("empty")
+		g.Lock()
+		err = g.readErr
+		g.Unlock()
+		if err == nil {
+			err = errors.New("empty")
+		}
 	}
 	return
 }
 
 func (g *testWS) Disconnected() bool {
+	g.Lock()
+	defer g.Unlock()
 	return g.disconnected
 }
 
@@ -100,6 +128,10 @@ func TestManager_reconnect(t *testing.T) {
 		disconnected: true,
 	}
 
+	// identify again without delay after the session is invalidated
+	identifyLimiter := NewIdentifyLimiter()
+	identifyLimiter.Interval = 0
+
 	m := &Client{
 		conf: &Config{
 			// identity
@@ -120,14 +152,13 @@ func TestManager_reconnect(t *testing.T) {
 			HTTPClient: &http.Client{
 				Timeout: time.Second * 10,
 			},
+			IdentifyLimiter: identifyLimiter,
 		},
-		shutdown:     make(chan interface{}),
-		restart:      make(chan interface{}),
-		eventChan:    make(chan *Event),
-		receiveChan:  make(chan *discordPacket),
-		emitChan:     make(chan *clientPacket),
-		conn:         conn,
-		disconnected: true,
+		shutdown:    make(chan interface{}),
+		eventChan:   make(chan *Event),
+		receiveChan: make(chan *discordPacket),
+		emitChan:    make(chan *clientPacket),
+		conn:        conn,
 	}
 	seq := uint(1)
 
@@ -177,6 +208,8 @@ func TestManager_reconnect(t *testing.T) {
 				conn.reading <- []byte(`{"t":null,"s":null,"op":11,"d":null}`)
 				wg[heartbeat].Done()
 			case opcode.Identify:
+				// a new session starts counting from the beginning
+				*seq = 1
 				conn.reading <- []byte(`{"t":"READY","s":` + strconv.Itoa(int(*seq)) + `,"op":0,"d":{}}`)
 				*seq++
 				wg[identify].Done()
@@ -247,3 +280,60 @@ func TestManager_reconnect(t *testing.T) {
 	// wait for identify
 	wg[identify].Wait()
 }
+
+func TestClient_presence(t *testing.T) {
+	conn := &testWS{
+		closing:      make(chan interface{}, 10),
+		opening:      make(chan interface{}, 10),
+		writing:      make(chan interface{}, 100),
+		reading:      make(chan []byte),
+		disconnected: true,
+	}
+	m := newStateTestClient(conn)
+	m.conf.Presence = map[string]string{"status": "idle"}
+	m.Start()
+	defer m.Shutdown()
+
+	// next returns the data of the next packet written with the given operation code
+	next := func(op uint) string {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case v := <-conn.writing:
+				packet := v.(*clientPacket)
+				if packet.Op != op {
+					continue
+				}
+				// the presences are maps, which httd.Marshal can not encode with every version of reflect2
+				data, err := json.Marshal(packet.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(data)
+			case <-timeout:
+				t.Fatalf("expected a packet with operation code %d", op)
+			}
+		}
+	}
+
+	// the presence is sent in the identify packet, as the client is not connected yet
+	if err := m.Emit(event.StatusUpdate, map[string]string{"status": "dnd"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	conn.reading <- []byte(`{"t":null,"s":null,"op":10,"d":{"heartbeat_interval":45000}}`)
+	if identify := next(opcode.Identify); !strings.Contains(identify, `"presence":{"status":"dnd"}`) {
+		t.Errorf("expected the last presence in the identify packet, got %s", identify)
+	}
+
+	conn.reading <- []byte(`{"t":"READY","s":1,"op":0,"d":{"session_id":"a2fa7ca3b1c5d8e1"}}`)
+	expectStates(t, m, StateConnecting, StateIdentifying, StateReady)
+	if err := m.Emit(event.StatusUpdate, map[string]string{"status": "online"}); err != nil {
+		t.Fatal(err)
+	}
+	if update := next(opcode.StatusUpdate); update != `{"status":"online"}` {
+		t.Errorf("expected the status update to be sent, got %s", update)
+	}
+}
@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/andersfylling/disgord/websocket/closecode"
+	"strconv"
+)
+
+// ErrorFatalClose is returned when Discord closed the connection with a close code that reconnecting can not
+// recover from, such as an invalid token. The client is left disconnected.
+type ErrorFatalClose struct {
+	ShardID uint
+	Code    int
+	Reason  string
+}
+
+func (e *ErrorFatalClose) Error() string {
+	return "shard " + strconv.Itoa(int(e.ShardID)) + " was closed by Discord with close code " +
+		strconv.Itoa(e.Code) + ": " + e.Reason
+}
+
+type closeAction uint8
+
+const (
+	closeResume closeAction = iota
+	closeIdentify
+	closeFatal
+)
+
+// closeActionFor decides how to recover from the connection being closed. Unless the close code says
+// otherwise, the session is resumed.
+func closeActionFor(err error) closeAction {
+	closeErr, ok := err.(*ErrorUnexpectedClose)
+	if !ok {
+		return closeResume
+	}
+
+	switch closeErr.Code {
+	case closecode.NotAuthenticated, closecode.InvalidSequence, closecode.SessionTimeout:
+		return closeIdentify
+	case closecode.AuthenticationFailed, closecode.InvalidShard, closecode.ShardingRequired, closecode.InvalidAPIVersion:
+		return closeFatal
+	default:
+		return closeResume
+	}
+}
+
+// connectionLost reacts to the connection being closed by Discord, or the network
+func (m *Client) connectionLost(err error) {
+	switch closeActionFor(err) {
+	case closeIdentify:
+		m.Lock()
+		m.sessionID = ""
+		m.sequenceNumber = 0
+		m.Unlock()
+		m.saveSession()
+		m.reconnect(err)
+	case closeFatal:
+		closeErr := err.(*ErrorUnexpectedClose)
+		m.fail(&ErrorFatalClose{
+			ShardID: m.conf.ShardID,
+			Code:    closeErr.Code,
+			Reason:  closeErr.Reason,
+		})
+	default:
+		m.reconnect(err)
+	}
+}
+
+// fail disconnects the client for good. The error is returned by WaitForReady until Connect is called again.
+func (m *Client) fail(err error) {
+	m.Lock()
+	m.fatalErr = err
+	change := m.setState(StateDisconnected, err)
+	_ = m.disconnect()
+	m.Unlock()
+	m.notifyState(change)
+}
+
+// WaitForReady blocks until the client is ready. If Discord closed the connection for good, the
+// *ErrorFatalClose is returned. If the identify limiter refused to identify the client, the
+// *ErrorIdentifyBudget is returned.
+func (m *Client) WaitForReady(ctx context.Context) error {
+	for {
+		m.Lock()
+		if m.stateChanged == nil {
+			m.stateChanged = make(chan struct{})
+		}
+		state, changed, fatal := m.state, m.stateChanged, m.fatalErr
+		m.Unlock()
+
+		switch {
+		case state == StateReady:
+			return nil
+		case fatal != nil:
+			return fatal
+		case state == StateDisconnected || state == StateClosed:
+			return errors.New("the client is " + state.String())
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"github.com/andersfylling/disgord/websocket/closecode"
+	"testing"
+	"time"
+)
+
+func TestCloseActionFor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected closeAction
+	}{
+		{"network", errors.New("connection reset by peer"), closeResume},
+		{"abnormal closure", &ErrorUnexpectedClose{Code: 1006}, closeResume},
+		{"unknown error", &ErrorUnexpectedClose{Code: closecode.UnknownError}, closeResume},
+		{"rate limited", &ErrorUnexpectedClose{Code: closecode.RateLimited}, closeResume},
+		{"invalid sequence", &ErrorUnexpectedClose{Code: closecode.InvalidSequence}, closeIdentify},
+		{"session timeout", &ErrorUnexpectedClose{Code: closecode.SessionTimeout}, closeIdentify},
+		{"authentication failed", &ErrorUnexpectedClose{Code: closecode.AuthenticationFailed}, closeFatal},
+		{"invalid shard", &ErrorUnexpectedClose{Code: closecode.InvalidShard}, closeFatal},
+		{"sharding required", &ErrorUnexpectedClose{Code: closecode.ShardingRequired}, closeFatal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if action := closeActionFor(tc.err); action != tc.expected {
+				t.Errorf("expected action %d, got %d", tc.expected, action)
+			}
+		})
+	}
+}
+
+func TestClient_connectionLost(t *testing.T) {
+	hello := []byte(`{"t":null,"s":null,"op":10,"d":{"heartbeat_interval":45000}}`)
+	newConn := func() *testWS {
+		return &testWS{
+			closing:      make(chan interface{}, 10),
+			opening:      make(chan interface{}, 10),
+			writing:      make(chan interface{}, 100),
+			reading:      make(chan []byte),
+			disconnected: true,
+		}
+	}
+
+	t.Run("fatal", func(t *testing.T) {
+		conn := newConn()
+		m := newStateTestClient(conn)
+		m.Start()
+		defer m.Shutdown()
+
+		if err := m.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		conn.reading <- hello
+		expectStates(t, m, StateConnecting, StateIdentifying)
+
+		conn.Lock()
+		conn.readErr = &ErrorUnexpectedClose{Code: closecode.AuthenticationFailed, Reason: "Authentication failed."}
+		conn.Unlock()
+		conn.reading <- nil
+		expectStates(t, m, StateDisconnected)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := m.WaitForReady(ctx)
+		if fatal, ok := err.(*ErrorFatalClose); !ok || fatal.Code != closecode.AuthenticationFailed {
+			t.Errorf("expected a fatal close error, got %v", err)
+		}
+		if len(conn.opening) != 1 {
+			t.Error("reconnected after a fatal close code")
+		}
+	})
+
+	t.Run("identify refused", func(t *testing.T) {
+		conn := newConn()
+		m := newStateTestClient(conn)
+		m.conf.IdentifyLimiter.Update(1000, 0, time.Hour, 1)
+		m.Start()
+		defer m.Shutdown()
+
+		if err := m.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		conn.reading <- hello
+		expectStates(t, m, StateConnecting, StateIdentifying, StateDisconnected)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, ok := m.WaitForReady(ctx).(*ErrorIdentifyBudget); !ok {
+			t.Error("expected the identify budget error")
+		}
+	})
+
+	t.Run("invalid sequence", func(t *testing.T) {
+		conn := newConn()
+		m := newStateTestClient(conn)
+		m.Start()
+		defer m.Shutdown()
+
+		if err := m.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		conn.reading <- hello
+		conn.reading <- []byte(`{"t":"READY","s":1,"op":0,"d":{"session_id":"a2fa7ca3b1c5d8e1"}}`)
+		expectStates(t, m, StateConnecting, StateIdentifying, StateReady)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := m.WaitForReady(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		conn.Lock()
+		conn.readErr = &ErrorUnexpectedClose{Code: closecode.InvalidSequence}
+		conn.Unlock()
+		conn.reading <- nil
+		expectStates(t, m, StateReconnecting, StateConnecting)
+		if m.Snapshot().Resumable() {
+			t.Error("expected the session to be dropped")
+		}
+
+		conn.Lock()
+		conn.readErr = nil
+		conn.Unlock()
+		conn.reading <- hello
+		expectStates(t, m, StateIdentifying)
+	})
+}
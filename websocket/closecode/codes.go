@@ -0,0 +1,19 @@
+package closecode
+
+// close codes sent by Discord when closing the socket connection
+// See https://discordapp.com/developers/docs/topics/opcodes-and-status-codes#gateway-gateway-close-event-codes
+const (
+	UnknownError int = 4000 + iota
+	UnknownOpCode
+	DecodeError
+	NotAuthenticated
+	AuthenticationFailed
+	AlreadyAuthenticated
+	_
+	InvalidSequence
+	RateLimited
+	SessionTimeout
+	InvalidShard
+	ShardingRequired
+	InvalidAPIVersion
+)
@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/url"
+)
+
+// CompressionZlibStream compresses every packet sent by Discord using one zlib context for the whole
+// connection. See https://discordapp.com/developers/docs/topics/gateway#transport-compression
+const CompressionZlibStream = "zlib-stream"
+
+// zlibSuffix ends every complete zlib-stream payload (Z_SYNC_FLUSH)
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+// gatewayURL adds the transport compression to the query of the gateway endpoint
+func gatewayURL(endpoint, compression string) (string, error) {
+	if compression == "" {
+		return endpoint, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("compress", compression)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func newZlibStream() *zlibStream {
+	return &zlibStream{
+		// flate never holds more than its 32KB window of decompressed data, so a larger buffer is always
+		// emptied by a single read
+		buf: make([]byte, 64*1024),
+	}
+}
+
+// zlibStream inflates the messages of a zlib-stream connection. The inflater is kept between messages, as
+// each message depends on the compression context of the previous ones.
+type zlibStream struct {
+	compressed bytes.Buffer
+	inflater   io.ReadCloser
+	buf        []byte
+}
+
+// reset must be called for every new connection, as Discord starts a new zlib context
+func (z *zlibStream) reset() {
+	if z.inflater != nil {
+		z.inflater.Close()
+		z.inflater = nil
+	}
+	z.compressed.Reset()
+}
+
+// decompress adds a websocket message to the stream. A payload may be split across several messages, in which
+// case ok is false until the last part is received.
+func (z *zlibStream) decompress(message []byte) (packet []byte, ok bool, err error) {
+	z.compressed.Write(message)
+	if !bytes.HasSuffix(message, zlibSuffix) {
+		return nil, false, nil
+	}
+
+	if z.inflater == nil {
+		if z.inflater, err = zlib.NewReader(&z.compressed); err != nil {
+			z.compressed.Reset()
+			return
+		}
+	}
+
+	// the inflater must not read past the buffered input, or it fails on the missing data and can not be
+	// used for the next message
+	output := new(bytes.Buffer)
+	for {
+		var n int
+		n, err = z.inflater.Read(z.buf)
+		output.Write(z.buf[:n])
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("zlib-stream was closed by Discord")
+			}
+			return
+		}
+
+		if z.compressed.Len() == 0 && n < len(z.buf) {
+			break
+		}
+	}
+
+	return output.Bytes(), true, nil
+}
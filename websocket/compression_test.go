@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/andersfylling/disgord/constant"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGatewayURL(t *testing.T) {
+	endpoint := "wss://gateway.discord.gg/?v=6&encoding=json"
+
+	if u, err := gatewayURL(endpoint, ""); err != nil || u != endpoint {
+		t.Errorf("the endpoint should not change without compression, got %s", u)
+	}
+
+	u, err := gatewayURL(endpoint, CompressionZlibStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "wss://gateway.discord.gg/?compress=zlib-stream&encoding=json&v=6"; u != expected {
+		t.Errorf("expected %s, got %s", expected, u)
+	}
+}
+
+func TestZlibStream(t *testing.T) {
+	large, err := ioutil.ReadFile("testdata/large.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := [][]byte{
+		[]byte(`{"op":10,"d":{"heartbeat_interval":41250}}`),
+		large,
+		[]byte(`{"op":11,"d":null}`),
+		large,
+	}
+
+	// Discord compresses every payload with the same zlib context, and flushes after each one
+	compressed := new(bytes.Buffer)
+	w := zlib.NewWriter(compressed)
+	var messages [][]byte
+	for _, payload := range payloads {
+		w.Write(payload)
+		w.Flush()
+		messages = append(messages, append([]byte(nil), compressed.Bytes()...))
+		compressed.Reset()
+	}
+
+	z := newZlibStream()
+	for i, message := range messages {
+		var packet []byte
+		var ok bool
+		if i == 1 {
+			// split the payload across two websocket messages
+			if packet, ok, err = z.decompress(message[:len(message)/2]); ok || err != nil {
+				t.Fatalf("a partial payload was decompressed, ok=%t err=%v", ok, err)
+			}
+			message = message[len(message)/2:]
+		}
+
+		if packet, ok, err = z.decompress(message); err != nil || !ok {
+			t.Fatalf("payload %d could not be decompressed, ok=%t err=%v", i, ok, err)
+		}
+		if !bytes.Equal(packet, payloads[i]) {
+			t.Errorf("payload %d was not decompressed correctly", i)
+		}
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		z.reset()
+
+		compressed.Reset()
+		w = zlib.NewWriter(compressed)
+		w.Write(payloads[0])
+		w.Flush()
+
+		packet, ok, err := z.decompress(compressed.Bytes())
+		if err != nil || !ok || !bytes.Equal(packet, payloads[0]) {
+			t.Errorf("a new zlib context was not accepted after reset, ok=%t err=%v", ok, err)
+		}
+	})
+}
+
+func TestGorilla_Read(t *testing.T) {
+	large, err := ioutil.ReadFile("testdata/large.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := new(bytes.Buffer)
+	w := zlib.NewWriter(compressed)
+	w.Write(large)
+	w.Flush()
+
+	// a zlib-stream message split where the second part looks like an etf payload
+	stream := append([]byte(nil), compressed.Bytes()...)
+	split := bytes.IndexByte(stream[1:], etfVersion) + 1
+	if split == 0 {
+		t.Fatal("the compressed payload must hold the etf version byte")
+	}
+
+	// a payload compressed on its own
+	w.Close()
+	compressed.Reset()
+	w.Reset(compressed)
+	w.Write(large)
+	w.Close()
+	payload := compressed.Bytes()
+
+	etf := []byte{etfVersion, 116, 0, 0, 0, 0}
+	tests := []struct {
+		name        string
+		compression string
+		encoding    string
+		messages    [][]byte
+		expected    []byte
+	}{
+		{"zlib-stream", CompressionZlibStream, constant.ETFEncoding, [][]byte{stream[:split], stream[split:]}, large},
+		{"etf", "", constant.ETFEncoding, [][]byte{etf}, etf},
+		{"compressed json", "", constant.JSONEncoding, [][]byte{payload}, large},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				for _, message := range test.messages {
+					conn.WriteMessage(websocket.BinaryMessage, message)
+				}
+				conn.ReadMessage() // wait for the client to close
+			}))
+			defer srv.Close()
+
+			conn, err := newConn(srv.Client(), test.compression, test.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = conn.Open("ws"+strings.TrimPrefix(srv.URL, "http"), nil); err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			packet, err := conn.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(packet, test.expected) {
+				t.Errorf("expected the payload of %d bytes, got %d bytes", len(test.expected), len(packet))
+			}
+		})
+	}
+}
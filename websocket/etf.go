@@ -0,0 +1,605 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/andersfylling/disgord/httd"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// External Term Format, as used by erlpack.
+// See http://erlang.org/doc/apps/erts/erl_ext_dist.html
+const (
+	etfVersion = 131
+
+	etfNewFloat       = 70
+	etfCompressed     = 80
+	etfSmallInteger   = 97
+	etfInteger        = 98
+	etfFloat          = 99
+	etfAtom           = 100
+	etfSmallTuple     = 104
+	etfLargeTuple     = 105
+	etfNil            = 106
+	etfString         = 107
+	etfList           = 108
+	etfBinary         = 109
+	etfSmallBig       = 110
+	etfLargeBig       = 111
+	etfMap            = 116
+	etfSmallAtom      = 115
+	etfAtomUTF8       = 118
+	etfSmallAtomUTF8  = 119
+	etfMaxSmallInt    = math.MaxUint8
+	etfMaxInteger     = math.MaxInt32
+	etfMinInteger     = math.MinInt32
+	etfMaxBigIntBytes = 8
+)
+
+var errETFUnexpectedEnd = errors.New("etf: unexpected end of data")
+
+// snowflakeKey reports whether integers under the given key are snowflakes. Discord sends snowflakes as integers
+// over ETF, while the structs expect them as JSON strings.
+func snowflakeKey(key []byte) bool {
+	switch string(key) {
+	case "id", "nonce", "roles", "mention_roles":
+		return true
+	}
+	return bytes.HasSuffix(key, []byte("_id")) || bytes.HasSuffix(key, []byte("_ids"))
+}
+
+// etfDecoder reads a term, and writes it as JSON such that event payloads can be unmarshalled into the same
+// structs regardless of the gateway encoding.
+type etfDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *etfDecoder) read(n int) (b []byte, err error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errETFUnexpectedEnd
+	}
+	b = d.data[d.pos : d.pos+n]
+	d.pos += n
+	return
+}
+
+func (d *etfDecoder) uint8() (uint8, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *etfDecoder) uint16() (uint16, error) {
+	b, err := d.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (d *etfDecoder) uint32() (uint32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// length reads the size prefix of a term, which is 1, 2 or 4 bytes long
+func (d *etfDecoder) length(size int) (n int, err error) {
+	switch size {
+	case 1:
+		var v uint8
+		v, err = d.uint8()
+		n = int(v)
+	case 2:
+		var v uint16
+		v, err = d.uint16()
+		n = int(v)
+	default:
+		var v uint32
+		v, err = d.uint32()
+		n = int(v)
+	}
+	return
+}
+
+// integer returns the decimal representation of an integer term
+func (d *etfDecoder) integer(tag byte) (string, error) {
+	switch tag {
+	case etfSmallInteger:
+		v, err := d.uint8()
+		return strconv.FormatUint(uint64(v), 10), err
+	case etfInteger:
+		v, err := d.uint32()
+		return strconv.FormatInt(int64(int32(v)), 10), err
+	case etfSmallBig, etfLargeBig:
+		size := 1
+		if tag == etfLargeBig {
+			size = 4
+		}
+		n, err := d.length(size)
+		if err != nil {
+			return "", err
+		}
+		sign, err := d.uint8()
+		if err != nil {
+			return "", err
+		}
+		digits, err := d.read(n)
+		if err != nil {
+			return "", err
+		}
+
+		var text string
+		if n <= etfMaxBigIntBytes {
+			var v uint64
+			for i := n - 1; i >= 0; i-- {
+				v = v<<8 | uint64(digits[i])
+			}
+			text = strconv.FormatUint(v, 10)
+		} else {
+			// the digits are little endian
+			bigEndian := make([]byte, n)
+			for i := range digits {
+				bigEndian[n-1-i] = digits[i]
+			}
+			text = new(big.Int).SetBytes(bigEndian).String()
+		}
+		if sign != 0 && text != "0" {
+			text = "-" + text
+		}
+		return text, nil
+	default:
+		return "", errors.New("etf: expected an integer, got tag " + strconv.Itoa(int(tag)))
+	}
+}
+
+// text returns the content of an atom, binary or string term
+func (d *etfDecoder) text(tag byte) ([]byte, error) {
+	var size int
+	switch tag {
+	case etfSmallAtom, etfSmallAtomUTF8:
+		size = 1
+	case etfAtom, etfAtomUTF8, etfString:
+		size = 2
+	case etfBinary:
+		size = 4
+	default:
+		return nil, errors.New("etf: expected a string, got tag " + strconv.Itoa(int(tag)))
+	}
+
+	n, err := d.length(size)
+	if err != nil {
+		return nil, err
+	}
+	return d.read(n)
+}
+
+func isAtom(tag byte) bool {
+	return tag == etfAtom || tag == etfSmallAtom || tag == etfAtomUTF8 || tag == etfSmallAtomUTF8
+}
+
+func isInteger(tag byte) bool {
+	return tag == etfSmallInteger || tag == etfInteger || tag == etfSmallBig || tag == etfLargeBig
+}
+
+// key returns a map key as a string
+func (d *etfDecoder) key() ([]byte, error) {
+	tag, err := d.uint8()
+	if err != nil {
+		return nil, err
+	}
+	if isInteger(tag) {
+		text, err := d.integer(tag)
+		return []byte(text), err
+	}
+	return d.text(tag)
+}
+
+// transcode writes the next term as JSON. The key is the map key the term belongs to, and decides whether
+// integers are written as snowflakes.
+func (d *etfDecoder) transcode(w *bytes.Buffer, key []byte) (err error) {
+	var tag byte
+	if tag, err = d.uint8(); err != nil {
+		return
+	}
+
+	switch {
+	case isInteger(tag):
+		var text string
+		if text, err = d.integer(tag); err != nil {
+			return
+		}
+		if snowflakeKey(key) {
+			w.WriteByte('"')
+			w.WriteString(text)
+			w.WriteByte('"')
+		} else {
+			w.WriteString(text)
+		}
+	case isAtom(tag):
+		var atom []byte
+		if atom, err = d.text(tag); err != nil {
+			return
+		}
+		switch string(atom) {
+		case "nil", "null":
+			w.WriteString("null")
+		case "true", "false":
+			w.Write(atom)
+		default:
+			writeJSONString(w, atom)
+		}
+	case tag == etfBinary || tag == etfString:
+		// erlpack decodes lists of small integers as strings
+		var text []byte
+		if text, err = d.text(tag); err != nil {
+			return
+		}
+		writeJSONString(w, text)
+	case tag == etfNewFloat:
+		var b []byte
+		if b, err = d.read(8); err != nil {
+			return
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(b))
+		w.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case tag == etfFloat:
+		var b []byte
+		if b, err = d.read(31); err != nil {
+			return
+		}
+		var f float64
+		if f, err = strconv.ParseFloat(strings.TrimRight(string(b), "\x00"), 64); err != nil {
+			return
+		}
+		w.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case tag == etfNil:
+		w.WriteString("[]")
+	case tag == etfList, tag == etfSmallTuple, tag == etfLargeTuple:
+		size := 4
+		if tag == etfSmallTuple {
+			size = 1
+		}
+		var n int
+		if n, err = d.length(size); err != nil {
+			return
+		}
+		w.WriteByte('[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err = d.transcode(w, key); err != nil {
+				return
+			}
+		}
+		w.WriteByte(']')
+
+		if tag == etfList {
+			var tail byte
+			if tail, err = d.uint8(); err != nil {
+				return
+			}
+			if tail != etfNil {
+				return errors.New("etf: improper lists are not supported")
+			}
+		}
+	case tag == etfMap:
+		var n int
+		if n, err = d.length(4); err != nil {
+			return
+		}
+		w.WriteByte('{')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			var k []byte
+			if k, err = d.key(); err != nil {
+				return
+			}
+			writeJSONString(w, k)
+			w.WriteByte(':')
+			if err = d.transcode(w, k); err != nil {
+				return
+			}
+		}
+		w.WriteByte('}')
+	case tag == etfCompressed:
+		var size int
+		if size, err = d.length(4); err != nil {
+			return
+		}
+		var inflated []byte
+		if inflated, err = d.inflate(size); err != nil {
+			return
+		}
+		return (&etfDecoder{data: inflated}).transcode(w, key)
+	default:
+		return errors.New("etf: unsupported tag " + strconv.Itoa(int(tag)))
+	}
+
+	return nil
+}
+
+// inflate decompresses a compressed term, and skips past it
+func (d *etfDecoder) inflate(size int) (inflated []byte, err error) {
+	compressed := bytes.NewReader(d.data[d.pos:])
+	r, err := zlib.NewReader(compressed)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	inflated = make([]byte, size)
+	if _, err = io.ReadFull(r, inflated); err != nil {
+		return
+	}
+	// reach the end of the zlib stream, such that the checksum is consumed
+	if _, err = io.Copy(ioutil.Discard, r); err != nil {
+		return
+	}
+
+	d.pos = len(d.data) - compressed.Len()
+	return
+}
+
+// writeJSONString writes s as a quoted JSON string
+func writeJSONString(w *bytes.Buffer, s []byte) {
+	const hex = "0123456789abcdef"
+
+	w.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+
+		w.Write(s[start:i])
+		switch c {
+		case '"', '\\':
+			w.WriteByte('\\')
+			w.WriteByte(c)
+		case '\n':
+			w.WriteString(`\n`)
+		case '\r':
+			w.WriteString(`\r`)
+		case '\t':
+			w.WriteString(`\t`)
+		default:
+			w.WriteString(`\u00`)
+			w.WriteByte(hex[c>>4])
+			w.WriteByte(hex[c&0xf])
+		}
+		start = i + 1
+	}
+	w.Write(s[start:])
+	w.WriteByte('"')
+}
+
+// UnmarshalETF decodes a packet sent by Discord using the etf encoding. The event payload is kept as JSON.
+func (p *discordPacket) UnmarshalETF(data []byte) (err error) {
+	d := &etfDecoder{data: data}
+
+	var version, tag byte
+	if version, err = d.uint8(); err != nil {
+		return
+	}
+	if version != etfVersion {
+		return errors.New("etf: unsupported version " + strconv.Itoa(int(version)))
+	}
+	if tag, err = d.uint8(); err != nil {
+		return
+	}
+	if tag != etfMap {
+		return errors.New("etf: expected the packet to be a map, got tag " + strconv.Itoa(int(tag)))
+	}
+
+	var n int
+	if n, err = d.length(4); err != nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		var key []byte
+		if key, err = d.key(); err != nil {
+			return
+		}
+
+		switch string(key) {
+		case "op", "s":
+			if tag, err = d.uint8(); err != nil {
+				return
+			}
+			if isAtom(tag) {
+				// null
+				_, err = d.text(tag)
+				break
+			}
+
+			var text string
+			if text, err = d.integer(tag); err != nil {
+				return
+			}
+			var v uint64
+			if v, err = strconv.ParseUint(text, 10, 64); err != nil {
+				return
+			}
+			if string(key) == "op" {
+				p.Op = uint(v)
+			} else {
+				p.SequenceNumber = uint(v)
+			}
+		case "t":
+			if tag, err = d.uint8(); err != nil {
+				return
+			}
+			var name []byte
+			if name, err = d.text(tag); err != nil {
+				return
+			}
+			if !isAtom(tag) || (string(name) != "nil" && string(name) != "null") {
+				p.EventName = string(name)
+			}
+		case "d":
+			w := new(bytes.Buffer)
+			w.Grow(len(data) - d.pos)
+			if err = d.transcode(w, nil); err != nil {
+				return
+			}
+			p.Data = w.Bytes()
+		default:
+			err = d.transcode(new(bytes.Buffer), key)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// etfMarshal encodes v using the etf encoding. v is first marshalled to JSON, such that the json tags and
+// custom marshalers of the structs are respected.
+func etfMarshal(v interface{}) (data []byte, err error) {
+	if data, err = httd.Marshal(v); err != nil {
+		return
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+
+	w := new(bytes.Buffer)
+	w.WriteByte(etfVersion)
+	if err = etfEncode(w, value); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func writeAtom(w *bytes.Buffer, atom string) {
+	w.WriteByte(etfSmallAtomUTF8)
+	w.WriteByte(byte(len(atom)))
+	w.WriteString(atom)
+}
+
+func writeUint32(w *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func writeBinary(w *bytes.Buffer, s string) {
+	w.WriteByte(etfBinary)
+	writeUint32(w, uint32(len(s)))
+	w.WriteString(s)
+}
+
+// etfEncode writes a value decoded from JSON as a term
+func etfEncode(w *bytes.Buffer, value interface{}) (err error) {
+	switch v := value.(type) {
+	case nil:
+		writeAtom(w, "nil")
+	case bool:
+		writeAtom(w, strconv.FormatBool(v))
+	case string:
+		writeBinary(w, v)
+	case json.Number:
+		return etfEncodeNumber(w, v)
+	case []interface{}:
+		if len(v) == 0 {
+			w.WriteByte(etfNil)
+			return
+		}
+		w.WriteByte(etfList)
+		writeUint32(w, uint32(len(v)))
+		for i := range v {
+			if err = etfEncode(w, v[i]); err != nil {
+				return
+			}
+		}
+		w.WriteByte(etfNil)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		w.WriteByte(etfMap)
+		writeUint32(w, uint32(len(keys)))
+		for _, key := range keys {
+			writeBinary(w, key)
+			if err = etfEncode(w, v[key]); err != nil {
+				return
+			}
+		}
+	default:
+		return errors.New("etf: unsupported value")
+	}
+
+	return nil
+}
+
+func etfEncodeNumber(w *bytes.Buffer, number json.Number) (err error) {
+	text := string(number)
+	negative := strings.HasPrefix(text, "-")
+
+	var magnitude uint64
+	if magnitude, err = strconv.ParseUint(strings.TrimPrefix(text, "-"), 10, 64); err != nil {
+		var f float64
+		if f, err = number.Float64(); err != nil {
+			return
+		}
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+		w.WriteByte(etfNewFloat)
+		w.Write(b[:])
+		return
+	}
+
+	switch {
+	case !negative && magnitude <= etfMaxSmallInt:
+		w.WriteByte(etfSmallInteger)
+		w.WriteByte(byte(magnitude))
+	case (!negative && magnitude <= etfMaxInteger) || (negative && magnitude <= -etfMinInteger):
+		w.WriteByte(etfInteger)
+		v := int64(magnitude)
+		if negative {
+			v = -v
+		}
+		writeUint32(w, uint32(int32(v)))
+	default:
+		var digits []byte
+		for ; magnitude > 0; magnitude >>= 8 {
+			digits = append(digits, byte(magnitude))
+		}
+		w.WriteByte(etfSmallBig)
+		w.WriteByte(byte(len(digits)))
+		if negative {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+		w.Write(digits)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/andersfylling/disgord/httd"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// snowflakesAsIntegers converts the snowflakes of a JSON value to integers, like Discord does over ETF
+func snowflakesAsIntegers(value interface{}, key string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if _, err := strconv.ParseUint(v, 10, 64); err == nil && snowflakeKey([]byte(key)) {
+			return json.Number(v)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = snowflakesAsIntegers(v[i], key)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = snowflakesAsIntegers(v[k], k)
+		}
+	}
+	return value
+}
+
+func decodeJSON(t testing.TB, data []byte) (value interface{}) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// discordETF encodes a JSON packet the way Discord would using the etf encoding
+func discordETF(t testing.TB, data []byte) []byte {
+	w := new(bytes.Buffer)
+	w.WriteByte(etfVersion)
+	if err := etfEncode(w, snowflakesAsIntegers(decodeJSON(t, data), "")); err != nil {
+		t.Fatal(err)
+	}
+	return w.Bytes()
+}
+
+// guildCreateJSON creates a large GUILD_CREATE packet. testdata/large.json is not valid JSON, and can not be
+// encoded as ETF.
+func guildCreateJSON(t testing.TB) []byte {
+	members := make([]interface{}, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		members = append(members, map[string]interface{}{
+			"user": map[string]interface{}{
+				"id":            strconv.Itoa(228846961774559232 + i),
+				"username":      "user " + strconv.Itoa(i),
+				"discriminator": "0001",
+				"avatar":        nil,
+			},
+			"roles":     []string{"486833611564253184", "486833041486905347"},
+			"joined_at": "2018-09-05T09:40:42.081000+00:00",
+			"mute":      false,
+			"deaf":      false,
+		})
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"id":           "486833041486905347",
+		"name":         "disgord",
+		"member_count": len(members),
+		"members":      members,
+		"large":        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the packet fields are in the order Discord sends them
+	return append([]byte(`{"t":"GUILD_CREATE","s":2,"op":0,"d":`), append(data, '}')...)
+}
+
+func TestDiscordPacket_UnmarshalETF(t *testing.T) {
+	var files [][]byte
+	for _, name := range []string{"1", "2", "3", "4", "small"} {
+		data, err := ioutil.ReadFile("testdata/" + name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, data)
+	}
+	files = append(files, guildCreateJSON(t))
+
+	for _, file := range files {
+		expected := discordPacket{}
+		if err := httd.Unmarshal(file, &expected); err != nil {
+			t.Fatal(err)
+		}
+
+		evt := discordPacket{}
+		if err := evt.UnmarshalETF(discordETF(t, file)); err != nil {
+			t.Fatal(err)
+		}
+
+		if evt.Op != expected.Op || evt.SequenceNumber != expected.SequenceNumber || evt.EventName != expected.EventName {
+			t.Errorf("expected %+v, got %+v", expected, evt)
+		}
+		if !reflect.DeepEqual(decodeJSON(t, evt.Data), decodeJSON(t, file).(map[string]interface{})["d"]) {
+			t.Errorf("the event payload of %s differs from the json encoding", expected.EventName)
+		}
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		data := discordETF(t, files[0])
+		for i := 0; i < len(data); i++ {
+			evt := discordPacket{}
+			if err := evt.UnmarshalETF(data[:i]); err == nil {
+				t.Fatalf("expected an error for a packet truncated to %d bytes", i)
+			}
+		}
+	})
+}
+
+func TestEtfDecoder_transcode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		key      string
+		data     []byte
+		expected string
+	}{
+		{"small integer", "", []byte{etfSmallInteger, 42}, `42`},
+		{"negative integer", "", []byte{etfInteger, 0xff, 0xff, 0xff, 0xfe}, `-2`},
+		{"snowflake", "channel_id", []byte{etfSmallBig, 8, 0, 0x00, 0xc0, 0x90, 0x37, 0x6f, 0xf4, 0x5d, 0x03}, `"242618713458655232"`},
+		{"negative big", "", []byte{etfSmallBig, 5, 1, 0x00, 0x00, 0x00, 0x00, 0x01}, `-4294967296`},
+		{"large big", "", []byte{etfLargeBig, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, `18446744073709551616`},
+		{"nil", "", []byte{etfSmallAtomUTF8, 3, 'n', 'i', 'l'}, `null`},
+		{"true", "", []byte{etfAtom, 0, 4, 't', 'r', 'u', 'e'}, `true`},
+		{"atom", "", []byte{etfSmallAtom, 2, 'o', 'k'}, `"ok"`},
+		{"float", "", []byte{etfNewFloat, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, `1.5`},
+		{"string", "", []byte{etfString, 0, 3, 'a', '"', '\n'}, `"a\"\n"`},
+		{"empty list", "", []byte{etfNil}, `[]`},
+		{"snowflake list", "roles", []byte{etfList, 0, 0, 0, 2, etfSmallInteger, 1, etfSmallInteger, 2, etfNil}, `["1","2"]`},
+		{"tuple", "", []byte{etfSmallTuple, 2, etfSmallInteger, 1, etfBinary, 0, 0, 0, 1, 'a'}, `[1,"a"]`},
+		{"map", "", []byte{etfMap, 0, 0, 0, 1, etfSmallAtom, 2, 'i', 'd', etfSmallInteger, 7}, `{"id":"7"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			d := &etfDecoder{data: tc.data}
+			if err := d.transcode(w, []byte(tc.key)); err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, w.String())
+			}
+			if d.pos != len(tc.data) {
+				t.Errorf("expected the whole term to be read, %d bytes left", len(tc.data)-d.pos)
+			}
+		})
+	}
+
+	t.Run("improper list", func(t *testing.T) {
+		d := &etfDecoder{data: []byte{etfList, 0, 0, 0, 1, etfSmallInteger, 1, etfSmallInteger, 2}}
+		if err := d.transcode(new(bytes.Buffer), nil); err == nil {
+			t.Error("expected an error for an improper list")
+		}
+	})
+}
+
+func TestEtfMarshal(t *testing.T) {
+	packet := &clientPacket{
+		Op: 8,
+		Data: struct {
+			GuildID  string   `json:"guild_id"`
+			Query    string   `json:"query"`
+			Limit    int      `json:"limit"`
+			Offset   int64    `json:"offset"`
+			Ratio    float64  `json:"ratio"`
+			Presence bool     `json:"presences"`
+			Nonce    *string  `json:"nonce"`
+			UserIDs  []string `json:"user_ids"`
+		}{"486833041486905347", "", 0, -5000000000, 0.25, true, nil, []string{}},
+	}
+
+	data, err := etfMarshal(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != etfVersion {
+		t.Fatalf("expected the etf version, got %d", data[0])
+	}
+
+	w := new(bytes.Buffer)
+	if err = (&etfDecoder{data: data[1:]}).transcode(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"d":{"guild_id":"486833041486905347","limit":0,"nonce":null,"offset":-5000000000,"presences":true,"query":"","ratio":0.25,"user_ids":[]},"op":8}`
+	if w.String() != expected {
+		t.Errorf("expected %s, got %s", expected, w.String())
+	}
+}
+
+func BenchmarkEvent_UnmarshalETF_small(b *testing.B) {
+	data, err := ioutil.ReadFile("testdata/small.json")
+	if err != nil {
+		return
+	}
+	data = discordETF(b, data)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		evt := discordPacket{}
+		evt.UnmarshalETF(data)
+	}
+}
+
+type benchmarkGuild struct {
+	ID      string `json:"id"`
+	Members []struct {
+		User struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Roles []string `json:"roles"`
+	} `json:"members"`
+}
+
+// the json and etf benchmarks of a large event includes unmarshalling the payload, as the etf payload is
+// transcoded to JSON
+
+func BenchmarkEvent_JSON_guildCreate(b *testing.B) {
+	data := guildCreateJSON(b)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		evt := discordPacket{}
+		evt.UnmarshalJSON(data)
+		httd.Unmarshal(evt.Data, &benchmarkGuild{})
+	}
+}
+
+func BenchmarkEvent_ETF_guildCreate(b *testing.B) {
+	data := discordETF(b, guildCreateJSON(b))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		evt := discordPacket{}
+		evt.UnmarshalETF(data)
+		httd.Unmarshal(evt.Data, &benchmarkGuild{})
+	}
+}
+
+func BenchmarkClientPacket_MarshalJSON(b *testing.B) {
+	packet := &clientPacket{Op: 1, Data: 251}
+	for n := 0; n < b.N; n++ {
+		httd.Marshal(packet)
+	}
+}
+
+func BenchmarkClientPacket_MarshalETF(b *testing.B) {
+	packet := &clientPacket{Op: 1, Data: 251}
+	for n := 0; n < b.N; n++ {
+		etfMarshal(packet)
+	}
+}
@@ -12,6 +12,9 @@ const (
 	RequestGuildMembers = "REQUEST_GUILD_MEMBERS"
 )
 
+// ConnectionStateChange is dispatched by the socket layer every time the connection state changes
+const ConnectionStateChange = "CONNECTION_STATE_CHANGE"
+
 // custom events for Disgord. Don't use these.
 const (
 	Shutdown = "_"
@@ -8,16 +8,12 @@ import (
 	"strconv"
 )
 
-const (
-	encodingJSON = "json"
-)
-
 type gatewayResponse struct {
 	URL string `json:"url"`
 }
 
 // getGatewayRoute get the connection endpoint for the session
-func getGatewayRoute(client *http.Client, version int) (url string, err error) {
+func getGatewayRoute(client *http.Client, version int, encoding string) (url string, err error) {
 	var resp *http.Response
 	resp, err = client.Get(endpoint.Gateway(version))
 	if err != nil {
@@ -37,6 +33,6 @@ func getGatewayRoute(client *http.Client, version int) (url string, err error) {
 		return
 	}
 
-	url = gatewayResponse.URL + "?v=" + strconv.Itoa(version) + "&encoding=" + encodingJSON
+	url = gatewayResponse.URL + "?v=" + strconv.Itoa(version) + "&encoding=" + encoding
 	return
 }
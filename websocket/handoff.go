@@ -0,0 +1,139 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/disgord/websocket/opcode"
+	"github.com/sirupsen/logrus"
+	"net"
+	"strconv"
+	"time"
+)
+
+// handOffTimeout is the time a hand off may take, once the next process has connected
+const handOffTimeout = 30 * time.Second
+
+type handOffRequest struct {
+	ShardIDs   []uint `json:"shard_ids"`
+	ShardCount uint   `json:"shard_count"`
+}
+
+type handOffResponse struct {
+	Sessions []*SessionSnapshot `json:"sessions"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// Detach shuts the client down without ending the Discord session, such that another process can resume it from
+// the returned snapshot. Events that are received after the snapshot is taken are not processed, and are instead
+// replayed by Discord to the process resuming the session.
+func (m *Client) Detach() (snapshot *SessionSnapshot, err error) {
+	m.Lock()
+	if m.state == StateClosed {
+		m.Unlock()
+		return nil, errors.New("already shut down")
+	}
+	change := m.setState(StateClosed, errors.New("the session was handed off"))
+	snapshot = m.snapshot()
+	if m.connClosed != nil {
+		close(m.connClosed)
+		m.connClosed = nil
+	}
+	connected := !m.conn.Disconnected()
+	m.Unlock()
+
+	if connected {
+		m.emitChan <- &clientPacket{Op: opcode.Detach}
+	}
+	m.notifyState(change)
+	close(m.shutdown)
+	m.saveSession()
+	return
+}
+
+func writeHandOffMessage(conn net.Conn, v interface{}) error {
+	data, err := httd.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+func readHandOffMessage(conn net.Conn, v interface{}) error {
+	data, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	return httd.Unmarshal(data, v)
+}
+
+// TakeOver asks the process listening on the Unix socket at path to hand over the sessions of the given shards,
+// such that they are resumed instead of identifying again. No sessions are returned if no process is listening.
+func TakeOver(path string, shardIDs []uint, shardCount uint) (sessions []*SessionSnapshot, err error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		logrus.Debug("no process to take the sessions over from: ", err)
+		return nil, nil
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(handOffTimeout))
+
+	err = writeHandOffMessage(conn, &handOffRequest{
+		ShardIDs:   shardIDs,
+		ShardCount: shardCount,
+	})
+	if err != nil {
+		return
+	}
+
+	response := &handOffResponse{}
+	if err = readHandOffMessage(conn, response); err != nil {
+		return
+	}
+	if response.Error != "" {
+		err = errors.New("the sessions were not handed off: " + response.Error)
+	}
+	return response.Sessions, err
+}
+
+// HandOff answers a TakeOver request from the next process. The clients of the requested shards are detached,
+// see Client.Detach, and their sessions are sent to the next process. The ids of the detached shards are
+// returned.
+func HandOff(conn net.Conn, clients map[uint]*Client) (shardIDs []uint, err error) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(handOffTimeout))
+
+	request := &handOffRequest{}
+	if err = readHandOffMessage(conn, request); err != nil {
+		return
+	}
+
+	response := &handOffResponse{}
+	for _, id := range request.ShardIDs {
+		client, exists := clients[id]
+		if exists && shardCount(client.conf.ShardCount) != shardCount(request.ShardCount) {
+			response.Error = "expected " + strconv.Itoa(int(shardCount(client.conf.ShardCount))) + " shards, got " +
+				strconv.Itoa(int(shardCount(request.ShardCount)))
+			return nil, writeHandOffMessage(conn, response)
+		}
+	}
+
+	for _, id := range request.ShardIDs {
+		client, exists := clients[id]
+		if !exists {
+			continue
+		}
+
+		snapshot, e := client.Detach()
+		if e != nil {
+			logrus.Error(e)
+			continue
+		}
+		response.Sessions = append(response.Sessions, snapshot)
+		shardIDs = append(shardIDs, id)
+	}
+
+	err = writeHandOffMessage(conn, response)
+	return
+}
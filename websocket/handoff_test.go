@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newReadyTestClient creates a client with an established session for the given shard
+func newReadyTestClient(t *testing.T, shardID, shardCount uint) (*Client, *testWS) {
+	conn := &testWS{
+		closing:      make(chan interface{}, 10),
+		opening:      make(chan interface{}, 10),
+		writing:      make(chan interface{}, 100),
+		reading:      make(chan []byte),
+		disconnected: true,
+	}
+	m := newStateTestClient(conn)
+	m.conf.ShardID = shardID
+	m.conf.ShardCount = shardCount
+	m.Start()
+
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	conn.reading <- []byte(`{"t":null,"s":null,"op":10,"d":{"heartbeat_interval":45000}}`)
+	conn.reading <- []byte(`{"t":"READY","s":1,"op":0,"d":{"session_id":"a2fa7ca3b1c5d8e1"}}`)
+	expectStates(t, m, StateConnecting, StateIdentifying, StateReady)
+	return m, conn
+}
+
+func TestHandOff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disgord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "handoff.sock")
+
+	if sessions, err := TakeOver(path, []uint{0}, 1); err != nil || sessions != nil {
+		t.Fatalf("expected no sessions without a listening process, got %+v, err=%v", sessions, err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	handOff := func(clients map[uint]*Client) chan []uint {
+		handedOff := make(chan []uint, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			ids, err := HandOff(conn, clients)
+			if err != nil {
+				t.Error(err)
+			}
+			handedOff <- ids
+		}()
+		return handedOff
+	}
+
+	m, conn := newReadyTestClient(t, 1, 2)
+
+	t.Run("shard count mismatch", func(t *testing.T) {
+		handedOff := handOff(map[uint]*Client{1: m})
+		if _, err := TakeOver(path, []uint{1}, 4); err == nil {
+			t.Error("expected the hand off to be refused")
+		}
+		if ids := <-handedOff; len(ids) != 0 || m.State() != StateReady {
+			t.Errorf("the client was detached, state %s", m.State())
+		}
+	})
+
+	handedOff := handOff(map[uint]*Client{1: m})
+	sessions, err := TakeOver(path, []uint{0, 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := <-handedOff; len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("expected shard 1 to be handed off, got %v", ids)
+	}
+
+	if len(sessions) != 1 {
+		t.Fatalf("expected one session, got %d", len(sessions))
+	}
+	session := sessions[0]
+	if session.SessionID != "a2fa7ca3b1c5d8e1" || session.SequenceNumber != 1 || session.ShardID != 1 || session.ShardCount != 2 {
+		t.Errorf("unexpected session %+v", session)
+	}
+
+	if m.State() != StateClosed {
+		t.Errorf("expected the client to be closed, got state %s", m.State())
+	}
+	conn.Lock()
+	resumable := conn.resumable
+	conn.Unlock()
+	if !resumable {
+		t.Error("the connection was closed in a way that ends the session")
+	}
+	if err = m.Shutdown(); err == nil {
+		t.Error("expected the detached client to already be shut down")
+	}
+}
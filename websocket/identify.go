@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IdentifyInterval is the time Discord requires between two identify packets in the same rate limit bucket
+const IdentifyInterval = 5 * time.Second
+
+// ErrorIdentifyBudget is returned when an identify is refused, as it would use the last identifies of the daily
+// session start limit.
+type ErrorIdentifyBudget struct {
+	Remaining uint
+	ResetAt   time.Time
+}
+
+func (e *ErrorIdentifyBudget) Error() string {
+	return "identify refused, " + strconv.Itoa(int(e.Remaining)) + " identifies left until " + e.ResetAt.String()
+}
+
+var identifyLimiters = struct {
+	sync.Mutex
+	limiters map[string]*IdentifyLimiter
+}{limiters: make(map[string]*IdentifyLimiter)}
+
+// IdentifyLimiterFor returns the identify limiter shared by every client in this process using the given token
+func IdentifyLimiterFor(token string) *IdentifyLimiter {
+	identifyLimiters.Lock()
+	defer identifyLimiters.Unlock()
+
+	limiter, exists := identifyLimiters.limiters[token]
+	if !exists {
+		limiter = NewIdentifyLimiter()
+		identifyLimiters.limiters[token] = limiter
+	}
+	return limiter
+}
+
+// NewIdentifyLimiter creates an identify limiter with unknown limits, which allows one identify per Interval until
+// Update is called.
+func NewIdentifyLimiter() *IdentifyLimiter {
+	return &IdentifyLimiter{
+		maxConcurrency: 1,
+		next:           make(map[uint]time.Time),
+		Interval:       IdentifyInterval,
+	}
+}
+
+// IdentifyLimiter queues the identify packets of every shard using the same bot token, given the session start
+// limit from the Gateway Bot endpoint. Shards are split into max_concurrency buckets, and each bucket may
+// identify once per Interval. Identifies are refused when the daily budget reaches Reserve, such that
+// a reconnect loop can not use up the budget.
+// See https://discordapp.com/developers/docs/topics/gateway#session-start-limit-object
+type IdentifyLimiter struct {
+	sync.Mutex
+
+	known          bool
+	total          uint
+	remaining      uint
+	resetAt        time.Time
+	maxConcurrency uint
+
+	// next holds the earliest time each bucket can identify
+	next map[uint]time.Time
+
+	// Reserve is the number of identifies in the daily budget that are never used. Defaults to 0.
+	Reserve uint
+
+	// Interval is the time between two identifies in the same bucket. Defaults to IdentifyInterval.
+	Interval time.Duration
+}
+
+// Update sets the session start limit as given by the Gateway Bot endpoint
+func (l *IdentifyLimiter) Update(total, remaining uint, resetAfter time.Duration, maxConcurrency uint) {
+	l.Lock()
+	defer l.Unlock()
+
+	if maxConcurrency == 0 {
+		maxConcurrency = 1
+	}
+
+	l.known = true
+	l.total = total
+	l.remaining = remaining
+	l.resetAt = time.Now().Add(resetAfter)
+	l.maxConcurrency = maxConcurrency
+}
+
+// reset restores the daily budget once the reset time has passed. Must be called with the lock held.
+func (l *IdentifyLimiter) reset(now time.Time) {
+	if l.known && now.After(l.resetAt) {
+		l.remaining = l.total
+		l.resetAt = now.Add(24 * time.Hour)
+	}
+}
+
+// Remaining returns the number of identifies left before the session start limit resets. known is false until
+// Update is called.
+func (l *IdentifyLimiter) Remaining() (remaining uint, resetAt time.Time, known bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.reset(time.Now())
+	return l.remaining, l.resetAt, l.known
+}
+
+// MaxConcurrency returns the number of shards that may identify within the same Interval
+func (l *IdentifyLimiter) MaxConcurrency() uint {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.maxConcurrency
+}
+
+// Wait blocks until the shard may identify, and counts the identify against the daily budget. Shards in the
+// same bucket identify in the order they called Wait. A *ErrorIdentifyBudget is returned if the budget is
+// used up.
+func (l *IdentifyLimiter) Wait(ctx context.Context, shardID uint) (err error) {
+	l.Lock()
+	now := time.Now()
+	l.reset(now)
+	if l.known && l.remaining <= l.Reserve {
+		err = &ErrorIdentifyBudget{
+			Remaining: l.remaining,
+			ResetAt:   l.resetAt,
+		}
+		l.Unlock()
+		return
+	}
+
+	bucket := shardID % l.maxConcurrency
+	at := l.next[bucket]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[bucket] = at.Add(l.Interval)
+	if l.known {
+		l.remaining--
+	}
+	l.Unlock()
+
+	select {
+	case <-time.After(at.Sub(now)):
+		return nil
+	case <-ctx.Done():
+	}
+
+	// the identify was never sent
+	l.Lock()
+	if l.known && l.remaining < l.total {
+		l.remaining++
+	}
+	l.Unlock()
+	return ctx.Err()
+}
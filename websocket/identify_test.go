@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdentifyLimiterFor(t *testing.T) {
+	if IdentifyLimiterFor("a") != IdentifyLimiterFor("a") {
+		t.Error("clients using the same token must share the identify limiter")
+	}
+	if IdentifyLimiterFor("a") == IdentifyLimiterFor("b") {
+		t.Error("clients using different tokens must not share the identify limiter")
+	}
+}
+
+func TestIdentifyLimiter_Wait(t *testing.T) {
+	wait := func(limiter *IdentifyLimiter, shardID uint) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return limiter.Wait(ctx, shardID)
+	}
+
+	t.Run("unknown limits", func(t *testing.T) {
+		limiter := NewIdentifyLimiter()
+		if err := wait(limiter, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(limiter, 1); err != context.DeadlineExceeded {
+			t.Errorf("expected shards to identify one at a time, got %v", err)
+		}
+	})
+
+	t.Run("max concurrency", func(t *testing.T) {
+		limiter := NewIdentifyLimiter()
+		limiter.Update(1000, 1000, time.Hour, 2)
+
+		for _, id := range []uint{0, 1} {
+			if err := wait(limiter, id); err != nil {
+				t.Fatalf("shard %d should identify at once, got %v", id, err)
+			}
+		}
+		if err := wait(limiter, 2); err != context.DeadlineExceeded {
+			t.Errorf("expected shard 2 to wait for shard 0, got %v", err)
+		}
+
+		if remaining, _, known := limiter.Remaining(); !known || remaining != 998 {
+			t.Errorf("expected 998 identifies left, got %d", remaining)
+		}
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		limiter := NewIdentifyLimiter()
+		limiter.Update(1000, 3, time.Hour, 16)
+		limiter.Reserve = 1
+
+		for _, id := range []uint{0, 1} {
+			if err := wait(limiter, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := wait(limiter, 2)
+		if budgetErr, ok := err.(*ErrorIdentifyBudget); !ok || budgetErr.Remaining != 1 {
+			t.Errorf("expected the identify to be refused, got %v", err)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		limiter := NewIdentifyLimiter()
+		limiter.Update(1000, 0, 0, 1)
+		time.Sleep(time.Millisecond)
+
+		if err := wait(limiter, 0); err != nil {
+			t.Fatal(err)
+		}
+		if remaining, resetAt, _ := limiter.Remaining(); remaining != 999 || resetAt.Before(time.Now()) {
+			t.Errorf("the budget was not reset, got %d identifies left until %s", remaining, resetAt)
+		}
+	})
+}
@@ -20,6 +20,7 @@ const (
 const (
 	Shutdown uint = 100
 	Close    uint = 101
+	Detach   uint = 102
 )
 
 // OperationCodeHolder Used on objects that holds a operation code
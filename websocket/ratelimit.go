@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/andersfylling/disgord/websocket/opcode"
+	"sync"
+	"time"
+)
+
+const (
+	// CommandBudget is the number of commands Discord accepts per connection within CommandInterval. A client
+	// exceeding it is disconnected.
+	CommandBudget = 120
+
+	// CommandInterval is the time window of the CommandBudget
+	CommandInterval = time.Minute
+
+	// CommandHeadroom is the part of the CommandBudget reserved for heartbeats, identify and resume packets, such
+	// that a burst of commands can not cause the connection to time out.
+	CommandHeadroom = 5
+
+	// commandLimit is the number of commands, other than heartbeats, identify and resume packets, per
+	// CommandInterval
+	commandLimit = CommandBudget - CommandHeadroom
+)
+
+// ErrorCommandBudget is returned by Emit when the command budget of the connection is used up, and the client
+// is configured to reject commands instead of waiting. See Config.RejectRateLimitedCommands.
+type ErrorCommandBudget struct {
+	Command    string
+	RetryAfter time.Duration
+}
+
+func (e *ErrorCommandBudget) Error() string {
+	return "command " + e.Command + " refused, the gateway command budget is used up for another " + e.RetryAfter.String()
+}
+
+// commandLimiter keeps track of the commands sent over a connection within the last CommandInterval
+type commandLimiter struct {
+	sync.Mutex
+
+	// sent holds the time of every command within the last CommandInterval, oldest first
+	sent []time.Time
+}
+
+// clean forgets the commands that no longer count against the budget. Must be called with the lock held.
+func (l *commandLimiter) clean(now time.Time) {
+	expired := 0
+	for expired < len(l.sent) && !now.Before(l.sent[expired].Add(CommandInterval)) {
+		expired++
+	}
+	l.sent = l.sent[expired:]
+}
+
+// reserve counts a command against the budget. If the budget is used up, nothing is counted and the time until
+// the command can be sent is returned. Priority commands may use the headroom, and are always counted.
+func (l *commandLimiter) reserve(now time.Time, priority bool) (wait time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.clean(now)
+	if !priority && len(l.sent) >= commandLimit {
+		return l.sent[len(l.sent)-commandLimit].Add(CommandInterval).Sub(now)
+	}
+	l.sent = append(l.sent, now)
+	return 0
+}
+
+// remaining returns the number of commands that can be sent right away
+func (l *commandLimiter) remaining(now time.Time) uint {
+	l.Lock()
+	defer l.Unlock()
+
+	l.clean(now)
+	if len(l.sent) >= commandLimit {
+		return 0
+	}
+	return uint(commandLimit - len(l.sent))
+}
+
+// reset restores the budget, as every connection has its own budget
+func (l *commandLimiter) reset() {
+	l.Lock()
+	defer l.Unlock()
+
+	l.sent = nil
+}
+
+// reserveCommand waits until the command fits in the budget of the connection, or refuses it if the client
+// is configured to do so. Heartbeats, identify and resume packets may use the headroom.
+func (m *Client) reserveCommand(command string, op uint) error {
+	var priority bool
+	switch op {
+	case opcode.Shutdown, opcode.Close:
+		// closing the connection is not a command
+		return nil
+	case opcode.Heartbeat, opcode.Identify, opcode.Resume:
+		priority = true
+	}
+
+	for {
+		wait := m.commands.reserve(time.Now(), priority)
+		if wait == 0 {
+			return nil
+		}
+		if m.conf.RejectRateLimitedCommands {
+			return &ErrorCommandBudget{
+				Command:    command,
+				RetryAfter: wait,
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-m.shutdown:
+			return errors.New("the client was shut down before the command was sent")
+		}
+	}
+}
+
+// RemainingCommands returns the number of commands that can be emitted over the current connection before the
+// command budget is used up. The headroom reserved for heartbeats is not included.
+func (m *Client) RemainingCommands() uint {
+	return m.commands.remaining(time.Now())
+}
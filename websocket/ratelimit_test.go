@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"github.com/andersfylling/disgord/websocket/event"
+	"testing"
+	"time"
+)
+
+func TestCommandLimiter(t *testing.T) {
+	limiter := &commandLimiter{}
+	now := time.Now()
+
+	for i := 0; i < commandLimit; i++ {
+		if wait := limiter.reserve(now.Add(time.Duration(i)*time.Millisecond), false); wait != 0 {
+			t.Fatalf("command #%d should be within the budget, got wait %s", i, wait)
+		}
+	}
+	now = now.Add(time.Second)
+	if remaining := limiter.remaining(now); remaining != 0 {
+		t.Errorf("expected the budget to be used up, got %d remaining", remaining)
+	}
+	if wait := limiter.reserve(now, false); wait != CommandInterval-time.Second {
+		t.Errorf("expected to wait until the first command expires, got %s", wait)
+	}
+
+	t.Run("headroom", func(t *testing.T) {
+		for i := 0; i < CommandHeadroom; i++ {
+			if wait := limiter.reserve(now, true); wait != 0 {
+				t.Fatalf("heartbeat #%d should use the headroom, got wait %s", i, wait)
+			}
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		later := now.Add(CommandInterval - time.Millisecond)
+		if remaining := limiter.remaining(later); remaining != commandLimit-CommandHeadroom {
+			t.Errorf("expected the headroom commands to still count, got %d remaining", remaining)
+		}
+		limiter.reset()
+		if remaining := limiter.remaining(later); remaining != commandLimit {
+			t.Errorf("expected a fresh budget after a reset, got %d remaining", remaining)
+		}
+	})
+}
+
+func TestClient_Emit_commandBudget(t *testing.T) {
+	m := &Client{
+		conf: &Config{
+			RejectRateLimitedCommands: true,
+		},
+		shutdown: make(chan interface{}),
+		emitChan: make(chan *clientPacket),
+	}
+	now := time.Now()
+	for i := 0; i < commandLimit; i++ {
+		m.commands.reserve(now, false)
+	}
+	if remaining := m.RemainingCommands(); remaining != 0 {
+		t.Errorf("expected the budget to be used up, got %d remaining", remaining)
+	}
+
+	err := m.Emit(event.RequestGuildMembers, struct{}{})
+	if budgetErr, ok := err.(*ErrorCommandBudget); !ok || budgetErr.RetryAfter <= 0 {
+		t.Errorf("expected the command to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/andersfylling/disgord/httd"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// SessionSnapshot holds what is needed to resume a gateway session, such that a new process can continue the
+// session of a shard instead of identifying again. Note that a session closed with Disconnect can not be resumed.
+type SessionSnapshot struct {
+	SessionID      string `json:"session_id"`
+	SequenceNumber uint   `json:"seq"`
+	ShardID        uint   `json:"shard_id"`
+	ShardCount     uint   `json:"shard_count"`
+	Endpoint       string `json:"endpoint"`
+}
+
+// Resumable checks if the snapshot holds a session
+func (s *SessionSnapshot) Resumable() bool {
+	return s != nil && s.SessionID != ""
+}
+
+// SessionStore persists the session of every shard. A snapshot is saved when a session is established or
+// resumed, on every heartbeat, and when the session is invalidated, in which case the snapshot is not
+// resumable. Save is called from the goroutines of the client and should return quickly.
+type SessionStore interface {
+	// Load returns the last saved snapshot of the shard, or nil if there is none
+	Load(shardID, shardCount uint) (*SessionSnapshot, error)
+	Save(snapshot *SessionSnapshot) error
+}
+
+// NewFileSessionStore creates a SessionStore which keeps one JSON file per shard in the given directory
+func NewFileSessionStore(dir string) *FileSessionStore {
+	return &FileSessionStore{dir: dir}
+}
+
+// FileSessionStore is a SessionStore for processes replacing each other on the same host
+type FileSessionStore struct {
+	dir string
+}
+
+var _ SessionStore = (*FileSessionStore)(nil)
+
+func (f *FileSessionStore) path(shardID, shardCount uint) string {
+	name := "session-" + strconv.Itoa(int(shardID)) + "-" + strconv.Itoa(int(shardCount)) + ".json"
+	return filepath.Join(f.dir, name)
+}
+
+// Load implements SessionStore
+func (f *FileSessionStore) Load(shardID, shardCount uint) (snapshot *SessionSnapshot, err error) {
+	data, err := ioutil.ReadFile(f.path(shardID, shardCount))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	snapshot = &SessionSnapshot{}
+	err = httd.Unmarshal(data, snapshot)
+	return
+}
+
+// Save implements SessionStore. The file is replaced atomically, such that a crash never leaves a partial
+// snapshot behind.
+func (f *FileSessionStore) Save(snapshot *SessionSnapshot) (err error) {
+	data, err := httd.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+
+	path := f.path(snapshot.ShardID, snapshot.ShardCount)
+	tmp, err := ioutil.TempFile(f.dir, filepath.Base(path))
+	if err != nil {
+		return
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// shardCount treats unsharded connections as a single shard
+func shardCount(count uint) uint {
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+// Snapshot returns the state needed to resume the current session. See SessionSnapshot.
+func (m *Client) Snapshot() *SessionSnapshot {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.snapshot()
+}
+
+// snapshot must be called with the lock held
+func (m *Client) snapshot() *SessionSnapshot {
+	return &SessionSnapshot{
+		SessionID:      m.sessionID,
+		SequenceNumber: m.sequenceNumber,
+		ShardID:        m.conf.ShardID,
+		ShardCount:     shardCount(m.conf.ShardCount),
+		Endpoint:       m.conf.Endpoint,
+	}
+}
+
+// restore seeds the client with a session, such that it resumes the session on the first connect. The endpoint
+// of the snapshot is only used if the config does not specify one, as it may use another encoding.
+func (m *Client) restore(snapshot *SessionSnapshot) error {
+	if snapshot.ShardID != m.conf.ShardID || shardCount(snapshot.ShardCount) != shardCount(m.conf.ShardCount) {
+		return errors.New("the session snapshot belongs to shard " + strconv.Itoa(int(snapshot.ShardID)) +
+			" of " + strconv.Itoa(int(shardCount(snapshot.ShardCount))))
+	}
+	if !snapshot.Resumable() {
+		return nil
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.sessionID = snapshot.SessionID
+	m.sequenceNumber = snapshot.SequenceNumber
+	if m.conf.Endpoint == "" {
+		m.conf.Endpoint = snapshot.Endpoint
+	}
+	return nil
+}
+
+// saveSession hands a snapshot of the session to the session store, if any
+func (m *Client) saveSession() {
+	if m.conf.SessionStore == nil {
+		return
+	}
+
+	if err := m.conf.SessionStore.Save(m.Snapshot()); err != nil {
+		logrus.Error(err)
+	}
+}
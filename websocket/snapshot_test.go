@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"github.com/andersfylling/disgord/constant"
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/disgord/websocket/opcode"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySessionStore struct {
+	sync.Mutex
+	snapshot *SessionSnapshot
+}
+
+func (s *memorySessionStore) Load(shardID, shardCount uint) (*SessionSnapshot, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.snapshot, nil
+}
+
+func (s *memorySessionStore) Save(snapshot *SessionSnapshot) error {
+	s.Lock()
+	defer s.Unlock()
+	s.snapshot = snapshot
+	return nil
+}
+
+func TestFileSessionStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disgord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileSessionStore(dir)
+
+	if snapshot, err := store.Load(0, 1); err != nil || snapshot != nil {
+		t.Errorf("expected no snapshot, got %+v, err=%v", snapshot, err)
+	}
+
+	snapshot := &SessionSnapshot{
+		SessionID:      "a2fa7ca3b1c5d8e1",
+		SequenceNumber: 1337,
+		ShardID:        3,
+		ShardCount:     4,
+		Endpoint:       "wss://gateway.discord.gg/?v=6&encoding=json",
+	}
+	if err = store.Save(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *loaded != *snapshot {
+		t.Errorf("expected %+v, got %+v", snapshot, loaded)
+	}
+
+	if snapshot, err := store.Load(3, 8); err != nil || snapshot != nil {
+		t.Errorf("the snapshot of another shard configuration was loaded, got %+v, err=%v", snapshot, err)
+	}
+}
+
+func TestNewClient_Session(t *testing.T) {
+	snapshot := &SessionSnapshot{
+		SessionID:      "a2fa7ca3b1c5d8e1",
+		SequenceNumber: 1337,
+		ShardID:        1,
+		ShardCount:     2,
+	}
+
+	t.Run("seeded", func(t *testing.T) {
+		client, err := NewClient(&Config{ShardID: 1, ShardCount: 2, Session: snapshot})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Shutdown()
+
+		if s := client.Snapshot(); s.SessionID != snapshot.SessionID || s.SequenceNumber != snapshot.SequenceNumber {
+			t.Errorf("the client was not seeded with the session, got %+v", s)
+		}
+	})
+
+	t.Run("store", func(t *testing.T) {
+		client, err := NewClient(&Config{ShardID: 1, ShardCount: 2, SessionStore: &memorySessionStore{snapshot: snapshot}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Shutdown()
+
+		if !client.Snapshot().Resumable() {
+			t.Error("the session was not loaded from the store")
+		}
+	})
+
+	t.Run("wrong shard", func(t *testing.T) {
+		if _, err := NewClient(&Config{ShardID: 0, ShardCount: 2, Session: snapshot}); err == nil {
+			t.Error("expected the session of another shard to be refused")
+		}
+	})
+}
+
+func TestClient_resumeOnFirstConnect(t *testing.T) {
+	conn := &testWS{
+		closing:      make(chan interface{}, 1),
+		opening:      make(chan interface{}, 1),
+		writing:      make(chan interface{}, 10),
+		reading:      make(chan []byte),
+		disconnected: true,
+	}
+	store := &memorySessionStore{}
+	m := &Client{
+		conf: &Config{
+			Token:        "token",
+			Endpoint:     "wss://gateway.discord.gg/?v=6&encoding=json",
+			Encoding:     constant.JSONEncoding,
+			ShardID:      1,
+			ShardCount:   2,
+			SessionStore: store,
+		},
+		shutdown:          make(chan interface{}),
+		eventChan:         make(chan *Event, 10),
+		receiveChan:       make(chan *discordPacket),
+		emitChan:          make(chan *clientPacket),
+		conn:              conn,
+		timeoutMultiplier: 1,
+	}
+	err := m.restore(&SessionSnapshot{SessionID: "a2fa7ca3b1c5d8e1", SequenceNumber: 42, ShardID: 1, ShardCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		close(m.shutdown)
+		conn.reading <- nil
+	}()
+
+	if err = m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	conn.reading <- []byte(`{"t":null,"s":null,"op":10,"d":{"heartbeat_interval":45000}}`)
+
+	timeout := time.After(time.Second)
+	for resumed := false; !resumed; {
+		select {
+		case v := <-conn.writing:
+			packet := v.(*clientPacket)
+			if packet.Op == opcode.Identify {
+				t.Fatal("identified instead of resuming the session")
+			}
+			if packet.Op != opcode.Resume {
+				continue
+			}
+
+			data, _ := httd.Marshal(packet.Data)
+			if !strings.Contains(string(data), `"session_id":"a2fa7ca3b1c5d8e1"`) || !strings.Contains(string(data), `"seq":42`) {
+				t.Errorf("unexpected resume payload %s", string(data))
+			}
+			resumed = true
+		case <-timeout:
+			t.Fatal("no resume packet was sent")
+		}
+	}
+
+	conn.reading <- []byte(`{"t":"RESUMED","s":43,"op":0,"d":{}}`)
+	for {
+		store.Lock()
+		snapshot := store.snapshot
+		store.Unlock()
+		if snapshot != nil && snapshot.SequenceNumber == 43 {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("the resumed session was not saved, got %+v", snapshot)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/disgord/websocket/event"
+	"github.com/sirupsen/logrus"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ConnectionState is the state of the gateway connection of a client
+type ConnectionState uint8
+
+// The states a client moves between. A client starts out Disconnected, and is Closed once Shutdown is called.
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateIdentifying
+	StateResuming
+	StateReady
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateIdentifying:
+		return "identifying"
+	case StateResuming:
+		return "resuming"
+	case StateReady:
+		return "ready"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChange describes a transition between two connection states. It is dispatched as the
+// event.ConnectionStateChange event.
+type StateChange struct {
+	ShardID uint            `json:"shard_id"`
+	From    ConnectionState `json:"from"`
+	To      ConnectionState `json:"to"`
+
+	// Attempt is the number of the reconnect attempt, and Delay is the time waited before it is made. Only set
+	// when reconnecting.
+	Attempt uint          `json:"attempt,omitempty"`
+	Delay   time.Duration `json:"delay,omitempty"`
+
+	// Reason is the error that caused the transition, if any
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReconnectPolicy decides how long to wait between reconnect attempts. The delay grows exponentially from
+// MinDelay up to MaxDelay, and Jitter spreads the reconnects of many shards over time.
+type ReconnectPolicy struct {
+	// MinDelay is the delay before the second attempt, the first attempt is made at once. Defaults to 1s.
+	MinDelay time.Duration
+
+	// MaxDelay caps the delay. Defaults to 2 minutes.
+	MaxDelay time.Duration
+
+	// Multiplier is the growth of the delay between two attempts. Defaults to 2.
+	Multiplier float64
+
+	// Jitter is the fraction of the delay that is random, in the range [0, 1]. A jitter of 0.5 gives a delay
+	// between 50% and 100% of the computed delay.
+	Jitter float64
+
+	// MaxAttempts is the number of reconnect attempts before the client gives up and is left disconnected.
+	// Zero means there is no limit.
+	MaxAttempts uint
+}
+
+// DefaultReconnectPolicy is used when no reconnect policy is configured. It never gives up.
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		MinDelay:   time.Second,
+		MaxDelay:   2 * time.Minute,
+		Multiplier: 2,
+		Jitter:     0.5,
+	}
+}
+
+// Delay returns the time to wait before the given reconnect attempt, starting at 1. The first attempt is made
+// at once.
+func (p *ReconnectPolicy) Delay(attempt uint) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+
+	min, max, multiplier := p.MinDelay, p.MaxDelay, p.Multiplier
+	if min <= 0 {
+		min = time.Second
+	}
+	if max <= 0 {
+		max = 2 * time.Minute
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(min) * math.Pow(multiplier, float64(attempt-2))
+	if delay > float64(max) {
+		delay = float64(max)
+	}
+
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+	delay -= delay * jitter * rand.Float64()
+	return time.Duration(delay)
+}
+
+// State returns the current connection state
+func (m *Client) State() ConnectionState {
+	m.RLock()
+	defer m.RUnlock()
+	return m.state
+}
+
+// setState moves the client into a new state. Must be called with the lock held. The returned change must be
+// dispatched with notifyState once the lock is released, and is nil if the state did not change.
+func (m *Client) setState(to ConnectionState, reason error) (change *StateChange) {
+	if m.state == to || m.state == StateClosed {
+		return nil
+	}
+
+	change = &StateChange{
+		ShardID: m.conf.ShardID,
+		From:    m.state,
+		To:      to,
+	}
+	if reason != nil {
+		change.Reason = reason.Error()
+	}
+	m.state = to
+	if m.stateChanged != nil {
+		close(m.stateChanged)
+	}
+	m.stateChanged = make(chan struct{})
+	return change
+}
+
+// transition moves the client into a new state and dispatches the change
+func (m *Client) transition(to ConnectionState, reason error) {
+	m.Lock()
+	change := m.setState(to, reason)
+	m.Unlock()
+
+	m.notifyState(change)
+}
+
+// notifyState dispatches a state change, if the event is of interest
+func (m *Client) notifyState(change *StateChange) {
+	if change == nil {
+		return
+	}
+	logrus.Debugf("shard %d: %s -> %s", change.ShardID, change.From, change.To)
+	if !m.eventOfInterest(event.ConnectionStateChange) {
+		return
+	}
+
+	data, err := httd.Marshal(change)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	select {
+	case m.eventChan <- &Event{Name: event.ConnectionStateChange, Data: data}:
+	case <-m.shutdown:
+	}
+}
+
+// reconnectPolicy returns the configured policy, or the default one
+func (m *Client) reconnectPolicy() *ReconnectPolicy {
+	if m.conf.ReconnectPolicy != nil {
+		return m.conf.ReconnectPolicy
+	}
+	return DefaultReconnectPolicy()
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"errors"
+	"github.com/andersfylling/disgord/constant"
+	"github.com/andersfylling/disgord/httd"
+	"github.com/andersfylling/disgord/websocket/event"
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicy_Delay(t *testing.T) {
+	policy := &ReconnectPolicy{
+		MinDelay:   time.Second,
+		MaxDelay:   10 * time.Second,
+		Multiplier: 2,
+	}
+
+	expected := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, delay := range expected {
+		if got := policy.Delay(uint(i + 1)); got != delay {
+			t.Errorf("expected attempt %d to wait %s, got %s", i+1, delay, got)
+		}
+	}
+
+	t.Run("jitter", func(t *testing.T) {
+		policy.Jitter = 0.5
+		for i := 0; i < 100; i++ {
+			if delay := policy.Delay(3); delay < time.Second || delay > 2*time.Second {
+				t.Fatalf("expected a delay between 1s and 2s, got %s", delay)
+			}
+		}
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		if delay := DefaultReconnectPolicy().Delay(1000); delay > 2*time.Minute {
+			t.Errorf("expected the delay to be capped, got %s", delay)
+		}
+	})
+}
+
+func newStateTestClient(conn *testWS) *Client {
+	identifyLimiter := NewIdentifyLimiter()
+	identifyLimiter.Interval = 0
+
+	m := &Client{
+		conf: &Config{
+			Token:           "token",
+			Endpoint:        "wss://gateway.discord.gg/?v=6&encoding=json",
+			Encoding:        constant.JSONEncoding,
+			IdentifyLimiter: identifyLimiter,
+			ReconnectPolicy: &ReconnectPolicy{MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		},
+		shutdown:    make(chan interface{}),
+		eventChan:   make(chan *Event, 100),
+		receiveChan: make(chan *discordPacket),
+		emitChan:    make(chan *clientPacket),
+		conn:        conn,
+	}
+	m.RegisterEvent(event.ConnectionStateChange)
+	return m
+}
+
+// expectStates reads the state changes dispatched by the client, and fails unless they match the given states
+func expectStates(t *testing.T, m *Client, states ...ConnectionState) (changes []*StateChange) {
+	timeout := time.After(time.Second)
+	for len(changes) < len(states) {
+		select {
+		case evt := <-m.eventChan:
+			if evt.Name != event.ConnectionStateChange {
+				continue
+			}
+
+			change := &StateChange{}
+			if err := httd.Unmarshal(evt.Data, change); err != nil {
+				t.Fatal(err)
+			}
+			if change.To != states[len(changes)] {
+				t.Fatalf("expected the state to change to %s, got %+v", states[len(changes)], change)
+			}
+			changes = append(changes, change)
+		case <-timeout:
+			t.Fatalf("expected the state to change to %s, got %d changes", states[len(changes)], len(changes))
+		}
+	}
+	return
+}
+
+func TestClient_stateChanges(t *testing.T) {
+	conn := &testWS{
+		closing:      make(chan interface{}, 10),
+		opening:      make(chan interface{}, 10),
+		writing:      make(chan interface{}, 100),
+		reading:      make(chan []byte),
+		disconnected: true,
+	}
+	m := newStateTestClient(conn)
+	m.Start()
+
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	expectStates(t, m, StateConnecting)
+	if err := m.Connect(); err == nil {
+		t.Error("expected a second connect to be refused")
+	}
+
+	conn.reading <- []byte(`{"t":null,"s":null,"op":10,"d":{"heartbeat_interval":45000}}`)
+	expectStates(t, m, StateIdentifying)
+	conn.reading <- []byte(`{"t":"READY","s":1,"op":0,"d":{"session_id":"a2fa7ca3b1c5d8e1"}}`)
+	expectStates(t, m, StateReady)
+
+	// the connection is lost
+	conn.reading <- nil
+	changes := expectStates(t, m, StateReconnecting, StateConnecting)
+	if changes[0].Attempt != 1 || changes[0].Reason != "empty" {
+		t.Errorf("unexpected reconnect %+v", changes[0])
+	}
+
+	conn.reading <- []byte(`{"t":null,"s":null,"op":10,"d":{"heartbeat_interval":45000}}`)
+	expectStates(t, m, StateResuming)
+	conn.reading <- []byte(`{"t":"RESUMED","s":2,"op":0,"d":{}}`)
+	expectStates(t, m, StateReady)
+
+	if err := m.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	expectStates(t, m, StateDisconnected)
+
+	// a connection closed on purpose is not reconnected
+	conn.reading <- nil
+	m.Shutdown()
+	expectStates(t, m, StateClosed)
+	if err := m.Connect(); err == nil {
+		t.Error("expected the client to refuse to connect after shutting down")
+	}
+}
+
+func TestClient_reconnectGivesUp(t *testing.T) {
+	conn := &testWS{
+		closing:      make(chan interface{}, 10),
+		opening:      make(chan interface{}, 10),
+		writing:      make(chan interface{}, 100),
+		reading:      make(chan []byte),
+		disconnected: true,
+		openErr:      errors.New("unreachable"),
+	}
+	m := newStateTestClient(conn)
+	m.conf.ReconnectPolicy.MaxAttempts = 2
+	m.state = StateReady
+	defer close(m.shutdown)
+
+	m.reconnect(errors.New("heartbeat ACK was not received"))
+
+	changes := expectStates(t, m, StateReconnecting, StateConnecting, StateReconnecting, StateConnecting, StateDisconnected)
+	if changes[2].Attempt != 2 || changes[2].Reason != "unreachable" {
+		t.Errorf("unexpected reconnect %+v", changes[2])
+	}
+	if m.State() != StateDisconnected {
+		t.Errorf("expected the client to give up, got state %s", m.State())
+	}
+}
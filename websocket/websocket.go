@@ -4,15 +4,24 @@ import "net/http"
 
 type Conn interface {
 	Close() error
+
+	// CloseResumable closes the connection without ending the Discord session, such that it can be resumed
+	CloseResumable() error
+
 	Open(endpoint string, requestHeader http.Header) error
 	WriteJSON(v interface{}) error
+	WriteETF(v interface{}) error
 	Read() (packet []byte, err error)
 
 	Disconnected() bool
 }
 
+// ErrorUnexpectedClose is returned by Conn.Read when the connection is closed by Discord. See the closecode
+// package for the close codes.
 type ErrorUnexpectedClose struct {
-	info string
+	Code   int
+	Reason string
+	info   string
 }
 
 func (e *ErrorUnexpectedClose) Error() string {
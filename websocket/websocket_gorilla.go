@@ -3,16 +3,27 @@ package websocket
 // TODO: if we add any other websocket packages, add build constraints to this file.
 
 import (
+	"errors"
+	"github.com/andersfylling/disgord/constant"
 	"github.com/andersfylling/disgord/httd"
 	"github.com/gorilla/websocket"
 	"io"
 	"net/http"
 )
 
-func newConn(HTTPClient *http.Client) (Conn, error) {
-	return &gorilla{
+func newConn(HTTPClient *http.Client, compression, encoding string) (Conn, error) {
+	g := &gorilla{
 		HTTPClient: HTTPClient,
-	}, nil
+		etf:        encoding == constant.ETFEncoding,
+	}
+	switch compression {
+	case "":
+	case CompressionZlibStream:
+		g.zlib = newZlibStream()
+	default:
+		return nil, errors.New("unsupported transport compression: " + compression)
+	}
+	return g, nil
 }
 
 // rwc is a wrapper for the Conn interface (not net.Conn).
@@ -21,6 +32,12 @@ func newConn(HTTPClient *http.Client) (Conn, error) {
 type gorilla struct {
 	c          *websocket.Conn
 	HTTPClient *http.Client
+
+	// zlib is the inflater for zlib-stream transport compression, if enabled
+	zlib *zlibStream
+
+	// etf is set when the gateway sends the payloads in the etf encoding, which are binary messages
+	etf bool
 }
 
 func (g *gorilla) Open(endpoint string, requestHeader http.Header) (err error) {
@@ -36,6 +53,10 @@ func (g *gorilla) Open(endpoint string, requestHeader http.Header) (err error) {
 		}
 	}
 
+	if g.zlib != nil {
+		g.zlib.reset()
+	}
+
 	// establish ws connection
 	g.c, _, err = dialer.Dial(endpoint, requestHeader)
 	return
@@ -52,29 +73,62 @@ func (g *gorilla) WriteJSON(v interface{}) (err error) {
 	return
 }
 
+func (g *gorilla) WriteETF(v interface{}) (err error) {
+	var data []byte
+	if data, err = etfMarshal(v); err != nil {
+		return
+	}
+	return g.c.WriteMessage(websocket.BinaryMessage, data)
+}
+
 func (g *gorilla) Close() (err error) {
 	err = g.c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 	g.c = nil
 	return
 }
 
+func (g *gorilla) CloseResumable() (err error) {
+	// Discord ends the session when the connection is closed with a normal closure
+	err = g.c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""))
+	g.c = nil
+	return
+}
+
 func (g *gorilla) Read() (packet []byte, err error) {
-	var messageType int
-	messageType, packet, err = g.c.ReadMessage()
-	if err != nil {
-		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-			err = &ErrorUnexpectedClose{
-				info: err.Error(),
+	for {
+		var messageType int
+		messageType, packet, err = g.c.ReadMessage()
+		if err != nil {
+			if closeErr, ok := err.(*websocket.CloseError); ok {
+				err = &ErrorUnexpectedClose{
+					Code:   closeErr.Code,
+					Reason: closeErr.Text,
+					info:   err.Error(),
+				}
 			}
+
+			return
 		}
 
-		return
-	}
+		if messageType != websocket.BinaryMessage {
+			return
+		}
 
-	if messageType == websocket.BinaryMessage {
-		packet, err = decompressBytes(packet)
+		switch {
+		case g.zlib != nil:
+			// the payload may be split across several messages
+			var ok bool
+			if packet, ok, err = g.zlib.decompress(packet); ok || err != nil {
+				return
+			}
+		case g.etf:
+			return
+		default:
+			// compressed json payload
+			packet, err = decompressBytes(packet)
+			return
+		}
 	}
-	return
 }
 
 func (g *gorilla) Disconnected() bool {